@@ -29,19 +29,22 @@ resource "kops_cluster" "aux_cluster" {
   associate_public_ip    = "true" // optional
   authorization          = "AlwaysAllow"
   bastion                = "false"
-  cloud                  = "aws" // Only AWS for now
+  cloud                  = "aws" // aws, gce, digitalocean or openstack. gce also needs project
   cloud_labels           = "Owner=Kalada Opuiyo,env=test"
+  deletion_protection    = "false" // optional, blocks terraform destroy when true
   dns                    = "public"
   dry_run                = "false" // not implemented
+  enable_delete_preview  = "false" // optional, lists the cloud resources a destroy would remove in delete_preview
   etcd_version           = "3.2.24"
   encrypt_etcd_storage   = "true"
+  hibernated             = "false" // optional, scales the node and bastion instance groups to zero and back
   image                  = "ami-03b850a018c8cd25e"
   k8s_version            = "v1.11.6"
   kube_dns               = "CoreDNS"
   master_per_zone        = 1  // optional, default is 1 per zone odd numbers only
   master_security_groups = [] // optional, not implemented
   master_size            = "t2.micro"
-  master_volume_size     = 20
+  master_volume_size     = 20             // root volumes can't be KMS encrypted on kops 1.11, use an AMI with encrypted snapshots
   master_zones           = ["us-east-1f"] // odd numbers only
   model                  = ""             // optional, not implemented
   name                   = "k8s.urbanradikal.com"
@@ -55,21 +58,39 @@ resource "kops_cluster" "aux_cluster" {
   node_zones             = ["us-east-1a", "us-east-1c"]
   out                    = ""            // optional, not implemented terraform or yaml
   output                 = ""            // optional, not implemented directory to output files output_dir
+  rollback_on_failure    = "false"       // optional, failed creates are resumed on the next apply by default
   ssh_access             = ["0.0.0.0/0"] // optional
   ssh_public_key         = "~/.ssh/kalada-admin.pub"
   state_store            = "s3://${aws_s3_bucket.kops_state.id}"
-  subnets                = []       // optional, not implemented
   target                 = ""       // optional, not implemented
   topology               = "public" // public, private
   utility_subnets        = []       // optional, not implemented
   network_id             = ""       // optional, not tested shared vpc id
 
+  rolling_update_cloud_only = "false" // optional, replace instances without draining or validation
+
   kubelet {
     anonymous_auth               = "false"
     authentication_token_webhook = "true"
     authorization_mode           = "Webhook"
   }
 
+  etcd_cluster {
+    name         = "main"
+    provider     = "Manager"
+    backup_store = "s3://${aws_s3_bucket.kops_state.id}/k8s.urbanradikal.com/backups/etcd/main"
+    volume_type  = "gp2"
+    volume_size  = 20
+  }
+
+  // Each step checks the time left before it starts, a running instance group update or
+  // cloud resource deletion is not interrupted and can run past these
+  timeouts {
+    create = "30m"
+    update = "60m"
+    delete = "20m"
+  }
+
 
   depends_on = ["aws_iam_user.kops"]
 }
@@ -106,6 +127,12 @@ output "cloud_resources" {
   }
 
 }
+output "ids_by_instance_group" {
+  value = "${data.kops_cloud_resources.cluster_cloud_resources.ids_by_instance_group}"
+}
+output "cluster_subnets" {
+  value = "${kops_cluster.aux_cluster.cluster_subnets}"
+}
 ##################################################################################################
 # S3
 ##################################################################################################
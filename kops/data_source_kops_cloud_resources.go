@@ -3,14 +3,13 @@ package kops
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kops/pkg/apis/kops"
 	"k8s.io/kops/pkg/client/simple/vfsclientset"
-	"k8s.io/kops/pkg/resources"
-	resourceops "k8s.io/kops/pkg/resources/ops"
-	"k8s.io/kops/upup/pkg/fi"
-	"k8s.io/kops/upup/pkg/fi/cloudup"
 	"k8s.io/kops/util/pkg/vfs"
 )
 
@@ -22,8 +21,6 @@ func dataSourceKopsCloudResources() *schema.Resource {
 }
 func dataSourceKopsCloudResourcesRead(d *schema.ResourceData, meta interface{}) error {
 
-	var cloud fi.Cloud
-
 	name := d.Get("cluster_name").(string)
 	d.SetId(name)
 
@@ -45,6 +42,10 @@ func dataSourceKopsCloudResourcesRead(d *schema.ResourceData, meta interface{})
 	securityGroupELBs := []string{}
 	subnets := []string{}
 	etcdVolumes := []string{}
+	loadBalancers := []string{}
+	cloudResources := []map[string]interface{}{}
+	idsByType := make(map[string][]string)
+	idsByInstanceGroup := make(map[string][]string)
 
 	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
 	if err != nil {
@@ -61,114 +62,145 @@ func dataSourceKopsCloudResourcesRead(d *schema.ResourceData, meta interface{})
 		return err
 	}
 
-	cloud, err = cloudup.BuildCloud(cluster)
+	_, clusterResources, err := listClusterResources(cluster, d.Get("include_shared").(bool))
 	if err != nil {
 		return err
 	}
 
-	allResources, err := resourceops.ListResources(cloud, name, "")
-	if err != nil {
-		return err
+	if v, ok := d.GetOk("types"); ok {
+		types := v.(*schema.Set)
+		for k, r := range clusterResources {
+			if !types.Contains(r.Type) {
+				delete(clusterResources, k)
+			}
+		}
 	}
 
-	clusterResources := make(map[string]*resources.Resource)
-	for k, resource := range allResources {
-		if resource.Shared {
-			continue
-		}
-		clusterResources[k] = resource
+	list, err := clientset.InstanceGroupsFor(cluster).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get InstanceGroups for %q: %v", name, err)
 	}
+
 	if len(clusterResources) == 0 {
-		fmt.Printf("No cloud resources found")
-	} else {
+		log.Printf("[INFO] No cloud resources found for Kops Cluster %s", name)
+	}
 
-		for _, v := range clusterResources {
+	for _, v := range sortedResources(clusterResources) {
 
-			switch v.Type {
+		ig := instanceGroupForResource(v, list.Items)
 
-			case "vpc":
-				d.Set("vpc_id", v.ID)
-			case "dhcp-options":
-				d.Set("dhcp_options_id", v.ID)
+		igName := ""
+		if ig != nil {
+			igName = ig.ObjectMeta.Name
+			idsByInstanceGroup[igName] = append(idsByInstanceGroup[igName], v.ID)
+		}
+		idsByType[v.Type] = append(idsByType[v.Type], v.ID)
+
+		cloudResources = append(cloudResources, map[string]interface{}{
+			"type":           v.Type,
+			"id":             v.ID,
+			"name":           v.Name,
+			"shared":         v.Shared,
+			"blocks":         v.Blocks,
+			"blocked":        v.Blocked,
+			"instance_group": igName,
+			"tags":           resourceTags(v),
+		})
+
+		switch v.Type {
+
+		case "vpc":
+			d.Set("vpc_id", v.ID)
+		case "dhcp-options":
+			d.Set("dhcp_options_id", v.ID)
+
+		case "internet-gateway":
+			d.Set("internet_gateway_id", v.ID)
+
+		case "route-table":
+			d.Set("route_table_id", v.ID)
+
+		case "load-balancer":
+			loadBalancers = append(loadBalancers, v.ID)
+			if strings.HasPrefix(v.Name, "api") {
+				d.Set("load_balancer_id", v.ID)
+			}
 
-			case "internet-gateway":
-				d.Set("internet_gateway_id", v.ID)
+		case "keypair":
+			d.Set("keypair_id", v.ID)
 
-			case "route-table":
-				d.Set("route_table_id", v.ID)
+		case "autoscaling-config":
+			if ig != nil && ig.Spec.Role == api.InstanceGroupRoleMaster {
+				autoScalingConfigMasters = append(autoScalingConfigMasters, v.ID)
+			} else if ig != nil && ig.Spec.Role == api.InstanceGroupRoleNode {
+				autoScalingConfigNodes = append(autoScalingConfigNodes, v.ID)
+			}
 
-			case "load-balancer":
-				d.Set("load_balancer_id", v.ID)
+		case "autoscaling-group":
+			if ig != nil && ig.Spec.Role == api.InstanceGroupRoleMaster {
+				autoScalingGroupMasters = append(autoScalingGroupMasters, v.ID)
+			} else if ig != nil && ig.Spec.Role == api.InstanceGroupRoleNode {
+				autoScalingGroupNodes = append(autoScalingGroupNodes, v.ID)
+			}
 
-			case "keypair":
-				d.Set("keypair_id", v.ID)
-
-			case "autoscaling-config":
-				if strings.Contains(v.Name, "master") {
-					autoScalingConfigMasters = append(autoScalingConfigMasters, v.ID)
-				} else {
-					autoScalingConfigNodes = append(autoScalingConfigNodes, v.ID)
-				}
-
-			case "autoscaling-group":
-				if strings.Contains(v.Name, "master") {
-					autoScalingGroupMasters = append(autoScalingGroupMasters, v.ID)
-				} else {
-					autoScalingGroupNodes = append(autoScalingGroupNodes, v.ID)
-				}
-
-			case "iam-instance-profile":
-				if strings.Contains(v.Name, "master") {
-					iamInstanceProfileMasters = append(iamInstanceProfileMasters, v.ID)
-				} else {
-					iamInstanceProfileNodes = append(iamInstanceProfileNodes, v.ID)
-				}
-
-			case "iam-role":
-				if strings.Contains(v.Name, "master") {
-					iamRoleMasters = append(iamRoleMasters, v.ID)
-				} else {
-					iamRoleNodes = append(iamRoleNodes, v.ID)
-				}
-
-			case "instance":
-				if strings.Contains(v.Name, "master") {
-					instanceMasters = append(instanceMasters, v.ID)
-				} else if strings.Contains(v.Name, "nodes") {
-					instanceNodes = append(instanceNodes, v.ID)
-				} else {
-					instanceBastion = append(instanceBastion, v.ID)
-				}
-
-			case "route53-record":
-				if strings.Contains(v.Name, "api") {
-					route53RecordsAPI = append(route53RecordsAPI, v.ID)
-
-				} else if strings.Contains(v.Name, "etcd") {
-					route53RecordsEtcd = append(route53RecordsEtcd, v.ID)
-				}
-
-			case "security-group":
-				if strings.Contains(v.Name, "master") {
-					securityGroupMasters = append(securityGroupMasters, v.ID)
-				} else if strings.Contains(v.Name, "nodes") {
-					securityGroupNodes = append(securityGroupNodes, v.ID)
-				} else if strings.Contains(v.Name, "elb") {
-					securityGroupELBs = append(securityGroupELBs, v.ID)
-				}
-
-			case "subnet":
-				subnets = append(subnets, v.ID)
-			case "volume":
-				etcdVolumes = append(etcdVolumes, v.ID)
-
-			default:
-				fmt.Printf("The resource type %s, is not implemented.\nid: %s", v.Name, v.ID)
+		case "iam-instance-profile":
+			if strings.HasPrefix(v.Name, "masters.") {
+				iamInstanceProfileMasters = append(iamInstanceProfileMasters, v.ID)
+			} else if strings.HasPrefix(v.Name, "nodes.") {
+				iamInstanceProfileNodes = append(iamInstanceProfileNodes, v.ID)
 			}
-		}
 
+		case "iam-role":
+			if strings.HasPrefix(v.Name, "masters.") {
+				iamRoleMasters = append(iamRoleMasters, v.ID)
+			} else if strings.HasPrefix(v.Name, "nodes.") {
+				iamRoleNodes = append(iamRoleNodes, v.ID)
+			}
+
+		case "instance":
+			if ig == nil {
+				break
+			}
+			switch ig.Spec.Role {
+			case api.InstanceGroupRoleMaster:
+				instanceMasters = append(instanceMasters, v.ID)
+			case api.InstanceGroupRoleNode:
+				instanceNodes = append(instanceNodes, v.ID)
+			case api.InstanceGroupRoleBastion:
+				instanceBastion = append(instanceBastion, v.ID)
+			}
+
+		case "route53-record":
+			if strings.HasPrefix(v.Name, "api") {
+				route53RecordsAPI = append(route53RecordsAPI, v.ID)
+
+			} else if strings.Contains(v.Name, "etcd") {
+				route53RecordsEtcd = append(route53RecordsEtcd, v.ID)
+			}
+
+		case "security-group":
+			if strings.HasPrefix(v.Name, "masters.") {
+				securityGroupMasters = append(securityGroupMasters, v.ID)
+			} else if strings.HasPrefix(v.Name, "nodes.") {
+				securityGroupNodes = append(securityGroupNodes, v.ID)
+			} else if strings.Contains(v.Name, "elb") {
+				securityGroupELBs = append(securityGroupELBs, v.ID)
+			}
+
+		case "subnet":
+			subnets = append(subnets, v.ID)
+		case "volume":
+			etcdVolumes = append(etcdVolumes, v.ID)
+
+		default:
+			log.Printf("[DEBUG] Resource type %s has no dedicated attribute, see resources. name: %s id: %s", v.Type, v.Name, v.ID)
+		}
 	}
 
+	d.Set("resources", cloudResources)
+	d.Set("ids_by_type", flattenIDs(idsByType, "type"))
+	d.Set("ids_by_instance_group", flattenIDs(idsByInstanceGroup, "instance_group"))
+	d.Set("load_balancers_id", loadBalancers)
 	d.Set("autoscaling_config_masters_id", autoScalingConfigMasters)
 	d.Set("autoscaling_config_nodes_id", autoScalingConfigNodes)
 	d.Set("autoscaling_group_masters_id", autoScalingGroupMasters)
@@ -190,3 +222,21 @@ func dataSourceKopsCloudResourcesRead(d *schema.ResourceData, meta interface{})
 
 	return nil
 }
+
+// flattenIDs turns ids into a list of blocks ordered by their key, which is set as the key attribute
+func flattenIDs(ids map[string][]string, key string) []map[string]interface{} {
+	keys := make([]string, 0, len(ids))
+	for k := range ids {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	l := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		l = append(l, map[string]interface{}{
+			key:   k,
+			"ids": ids[k],
+		})
+	}
+	return l
+}
@@ -0,0 +1,37 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  map[string][]string
+		want []map[string]interface{}
+	}{
+		{
+			name: "none",
+			want: []map[string]interface{}{},
+		},
+		{
+			name: "ordered by key",
+			ids: map[string][]string{
+				"nodes":             {"i-2", "i-3"},
+				"master-us-east-1a": {"i-1"},
+			},
+			want: []map[string]interface{}{
+				{"instance_group": "master-us-east-1a", "ids": []string{"i-1"}},
+				{"instance_group": "nodes", "ids": []string{"i-2", "i-3"}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got := flattenIDs(tc.ids, "instance_group")
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package kops
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/kops/pkg/util/templater"
+)
+
+func dataSourceKopsTemplate() *schema.Resource {
+	return &schema.Resource{
+		Read:   dataSourceKopsTemplateRead,
+		Schema: kopsTemplateSchema(),
+	}
+}
+
+// Sourced:k8s.io/kops/cmd/kops/toolbox_template.go
+func dataSourceKopsTemplateRead(d *schema.ResourceData, meta interface{}) error {
+
+	context := make(map[string]interface{})
+	if v, ok := d.GetOk("values_yaml"); ok {
+		if err := yaml.Unmarshal([]byte(v.(string)), &context); err != nil {
+			return fmt.Errorf("error parsing values_yaml: %v", err)
+		}
+	}
+	for k, v := range d.Get("values").(map[string]interface{}) {
+		context[k] = v
+	}
+
+	snippets := make(map[string]string)
+	for k, v := range d.Get("snippets").(map[string]interface{}) {
+		snippets[k] = fmt.Sprint(v)
+	}
+
+	r := templater.NewTemplater()
+	rendered, err := r.Render(d.Get("template").(string), context, snippets, d.Get("fail_on_missing").(bool))
+	if err != nil {
+		return fmt.Errorf("error rendering template: %v", err)
+	}
+
+	sections, err := splitManifest(rendered)
+	if err != nil {
+		return fmt.Errorf("error splitting rendered template: %v", err)
+	}
+	documents := []string{}
+	for _, section := range sections {
+		documents = append(documents, strings.TrimSpace(string(section))+"\n")
+	}
+
+	sum := sha1.Sum([]byte(rendered))
+	d.SetId(hex.EncodeToString(sum[:]))
+	d.Set("rendered", strings.Join(documents, "---\n"))
+	d.Set("documents", documents)
+
+	return nil
+}
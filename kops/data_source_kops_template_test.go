@@ -0,0 +1,60 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestDataSourceKopsTemplateRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "values and values_yaml",
+			raw: map[string]interface{}{
+				"template":    "name: {{ .name }}\nzones: {{ range .zones }}{{ . }} {{ end }}\n",
+				"values":      map[string]interface{}{"name": "test"},
+				"values_yaml": "zones: [a, b]\n",
+			},
+			want: []string{"name: test\nzones: a b\n"},
+		},
+		{
+			name: "one document per section",
+			raw: map[string]interface{}{
+				"template": "---\nkind: Cluster\n---\r\nkind: InstanceGroup\n",
+			},
+			want: []string{"kind: Cluster\n", "kind: InstanceGroup\n"},
+		},
+		{
+			name: "missing value",
+			raw: map[string]interface{}{
+				"template": "name: {{ .name }}\n",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		d := schema.TestResourceDataRaw(t, kopsTemplateSchema(), tc.raw)
+		err := dataSourceKopsTemplateRead(d, nil)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		got := expandStringList(d.Get("documents").([]interface{}))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
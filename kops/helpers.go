@@ -2,8 +2,25 @@ package kops
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/resources"
+	ops "k8s.io/kops/pkg/resources/ops"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/util/pkg/vfs"
 )
 
 // parseCloudLabels takes a CSV list of key=value records and parses them into a map. Nested '='s are supported via
@@ -32,3 +49,281 @@ func parseCloudLabels(s string) (map[string]string, error) {
 	}
 	return m, nil
 }
+
+// applySpecPatch applies a JSON merge patch (RFC 7386) or a strategic merge patch to obj, which must be a pointer
+// to a kops api object. obj is replaced by the patched result so fields set to null in the patch are removed
+func applySpecPatch(obj interface{}, patch, patchType string) error {
+
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch patchType {
+	case "merge":
+		patched, err = jsonpatch.MergePatch(original, []byte(patch))
+	case "strategic":
+		patched, err = strategicpatch.StrategicMergePatch(original, []byte(patch), obj)
+	default:
+		return fmt.Errorf("unknown patch type %q", patchType)
+	}
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return json.Unmarshal(patched, obj)
+}
+
+// subnetNameForZone returns the name of the subnet kops places instances of zone in. On GCE subnets
+// are regional and named after the region, e.g. us-central1-a is placed in us-central1
+func subnetNameForZone(cloud, zone string) string {
+	if cloud == "gce" {
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			return zone[:i]
+		}
+	}
+	return zone
+}
+
+// instanceGroupZones returns the zones an instance group runs in. GCE instance groups list them in
+// Zones, as their subnets are regional
+func instanceGroupZones(ig *api.InstanceGroup) []string {
+	if len(ig.Spec.Zones) != 0 {
+		return ig.Spec.Zones
+	}
+	return ig.Spec.Subnets
+}
+
+// listClusterResources returns the cloud along with every resource kops found for the cluster. Shared resources
+// such as a VPC passed in with network_id are left out unless includeShared is set, as kops will not delete them
+func listClusterResources(cluster *api.Cluster, includeShared bool) (fi.Cloud, map[string]*resources.Resource, error) {
+
+	cloud, err := cloudup.BuildCloud(cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allResources, err := ops.ListResources(cloud, cluster.ObjectMeta.Name, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clusterResources := make(map[string]*resources.Resource)
+	for k, resource := range allResources {
+		if resource.Shared && !includeShared {
+			continue
+		}
+		clusterResources[k] = resource
+	}
+	return cloud, clusterResources, nil
+}
+
+// sortedResources orders resources by type then name so computed attributes are stable between reads
+func sortedResources(clusterResources map[string]*resources.Resource) []*resources.Resource {
+
+	sorted := make([]*resources.Resource, 0, len(clusterResources))
+	for _, r := range clusterResources {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// flattenDeletePreview lists resources by type, name and id
+func flattenDeletePreview(clusterResources map[string]*resources.Resource) []map[string]interface{} {
+
+	preview := make([]map[string]interface{}, 0, len(clusterResources))
+	for _, r := range sortedResources(clusterResources) {
+		preview = append(preview, map[string]interface{}{
+			"type": r.Type,
+			"name": r.Name,
+			"id":   r.ID,
+		})
+	}
+	return preview
+}
+
+// instanceGroupForResource finds the instance group a cloud resource was created for. On AWS and DigitalOcean
+// kops names autoscaling groups, launch configurations, instances and droplets <instance group>.<...>.<cluster name>
+func instanceGroupForResource(r *resources.Resource, instanceGroups []api.InstanceGroup) *api.InstanceGroup {
+
+	var match func(igName string) bool
+	switch r.Type {
+	case "autoscaling-group", "autoscaling-config", "instance", "droplet":
+		match = func(igName string) bool { return strings.HasPrefix(r.Name, igName+".") }
+	// GCE names instances and templates <instance group>-..., and managed instance groups <zone>-<instance group>-<cluster>
+	case "Instance", "InstanceTemplate":
+		match = func(igName string) bool { return strings.HasPrefix(r.Name, igName+"-") }
+	case "InstanceGroupManager":
+		match = func(igName string) bool { return strings.Contains(r.Name, "-"+igName+"-") }
+	default:
+		return nil
+	}
+
+	// Prefer the longest name so nodes-2 doesn't get matched as nodes
+	var ig *api.InstanceGroup
+	for i := range instanceGroups {
+		name := instanceGroups[i].ObjectMeta.Name
+		if match(name) && (ig == nil || len(name) > len(ig.ObjectMeta.Name)) {
+			ig = &instanceGroups[i]
+		}
+	}
+	return ig
+}
+
+// remainingTime returns what is left until deadline for operation. kops has no way to cancel a
+// running apply, so each step is handed the remaining time rather than the whole resource timeout
+func remainingTime(deadline time.Time, operation string) (time.Duration, error) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return 0, fmt.Errorf("timeout reached before %s", operation)
+	}
+	return remaining, nil
+}
+
+// stateStoreFiles returns the paths of the files under configBase, a config base that doesn't
+// exist yet having none
+func stateStoreFiles(configBase vfs.Path) (map[string]bool, error) {
+
+	files, err := configBase.ReadTree()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error listing state store %q: %v", configBase.Path(), err)
+	}
+
+	stored := make(map[string]bool)
+	for _, p := range files {
+		stored[p.Path()] = true
+	}
+	return stored, nil
+}
+
+// addedStateStoreFiles returns the files not among the stored paths
+func addedStateStoreFiles(stored map[string]bool, files []vfs.Path) []vfs.Path {
+	var added []vfs.Path
+	for _, p := range files {
+		if !stored[p.Path()] {
+			added = append(added, p)
+		}
+	}
+	return added
+}
+
+// resourceTags returns the tags of the cloud object kops discovered while listing r. Only the
+// object types kops keeps a reference to carry tags, others return an empty map
+func resourceTags(r *resources.Resource) map[string]interface{} {
+
+	tags := make(map[string]interface{})
+
+	var ec2Tags []*ec2.Tag
+	switch obj := r.Obj.(type) {
+	case *ec2.Vpc:
+		ec2Tags = obj.Tags
+	case *ec2.Subnet:
+		ec2Tags = obj.Tags
+	case *ec2.SecurityGroup:
+		ec2Tags = obj.Tags
+	case *ec2.Instance:
+		ec2Tags = obj.Tags
+	case *ec2.RouteTable:
+		ec2Tags = obj.Tags
+	case *ec2.InternetGateway:
+		ec2Tags = obj.Tags
+	case *ec2.DhcpOptions:
+		ec2Tags = obj.Tags
+	case *ec2.Volume:
+		ec2Tags = obj.Tags
+	case *ec2.NatGateway:
+		ec2Tags = obj.Tags
+	case *autoscaling.Group:
+		for _, t := range obj.Tags {
+			tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+	}
+	for _, t := range ec2Tags {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags
+}
+
+// flattenSubnets lists the cluster subnets with the CIDRs assigned by kops
+func flattenSubnets(subnets []api.ClusterSubnetSpec) []map[string]interface{} {
+
+	l := make([]map[string]interface{}, 0, len(subnets))
+	for _, subnet := range subnets {
+		l = append(l, map[string]interface{}{
+			"name":        subnet.Name,
+			"zone":        subnet.Zone,
+			"region":      subnet.Region,
+			"cidr":        subnet.CIDR,
+			"type":        string(subnet.Type),
+			"provider_id": subnet.ProviderID,
+		})
+	}
+	return l
+}
+
+// expandStringList converts a Terraform list into a string slice
+func expandStringList(l []interface{}) []string {
+	s := make([]string, 0, len(l))
+	for _, v := range l {
+		s = append(s, fmt.Sprint(v))
+	}
+	return s
+}
+
+// spreadSize returns the share of total for group i of n, giving the remainder to the first groups
+func spreadSize(total int32, n int, i int) int32 {
+	size := total / int32(n)
+	if int32(i) < total%int32(n) {
+		size++
+	}
+	return size
+}
+
+// lifecycles are the task lifecycles that can be set through lifecycle_overrides
+var lifecycles = []fi.Lifecycle{
+	fi.LifecycleSync,
+	fi.LifecycleIgnore,
+	fi.LifecycleWarnIfInsufficientAccess,
+	fi.LifecycleExistsAndValidates,
+}
+
+// expandLifecycleOverrides converts a map of task type to lifecycle name, the same as kops update cluster --lifecycle-overrides
+func expandLifecycleOverrides(m map[string]interface{}) (map[string]fi.Lifecycle, error) {
+	overrides := make(map[string]fi.Lifecycle)
+	for taskType, v := range m {
+		lifecycle := fi.Lifecycle(fmt.Sprint(v))
+		found := false
+		for _, l := range lifecycles {
+			if l == lifecycle {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown lifecycle %q for %q, must be one of %v", lifecycle, taskType, lifecycles)
+		}
+		overrides[taskType] = lifecycle
+	}
+	return overrides, nil
+}
+
+// containsString reports whether s is in l
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/util/pkg/vfs"
+)
+
+func TestAddedStateStoreFiles(t *testing.T) {
+	paths := func(locations ...string) []vfs.Path {
+		var l []vfs.Path
+		for _, location := range locations {
+			l = append(l, vfs.NewFSPath(location))
+		}
+		return l
+	}
+
+	tests := []struct {
+		name   string
+		stored map[string]bool
+		files  []vfs.Path
+		want   []string
+	}{
+		{
+			name:  "empty state store",
+			files: paths("/s/c/config", "/s/c/instancegroup/nodes"),
+			want:  []string{"/s/c/config", "/s/c/instancegroup/nodes"},
+		},
+		{
+			name:   "files from an earlier attempt are kept",
+			stored: map[string]bool{"/s/c/pki/ssh/public/admin/key": true},
+			files:  paths("/s/c/config", "/s/c/pki/ssh/public/admin/key"),
+			want:   []string{"/s/c/config"},
+		},
+		{
+			name:   "nothing written",
+			stored: map[string]bool{"/s/c/config": true},
+			files:  paths("/s/c/config"),
+		},
+	}
+
+	for _, tc := range tests {
+		var got []string
+		for _, p := range addedStateStoreFiles(tc.stored, tc.files) {
+			got = append(got, p.Path())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRemainingTime(t *testing.T) {
+	if _, err := remainingTime(time.Now().Add(-time.Second), "applying cluster"); err == nil {
+		t.Errorf("expected an error once the deadline has passed")
+	}
+
+	remaining, err := remainingTime(time.Now().Add(time.Hour), "applying cluster")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if remaining <= 59*time.Minute || remaining > time.Hour {
+		t.Errorf("got %v remaining, want just under an hour", remaining)
+	}
+}
+
+func TestApplySpecPatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		patch     string
+		patchType string
+		wantErr   bool
+		check     func(ig *api.InstanceGroup) bool
+	}{
+		{
+			name:      "merge sets a field",
+			patch:     `{"spec":{"maxSize":5}}`,
+			patchType: "merge",
+			check: func(ig *api.InstanceGroup) bool {
+				return fi.Int32Value(ig.Spec.MaxSize) == 5 && ig.Spec.MachineType == "t2.medium"
+			},
+		},
+		{
+			name:      "merge removes a field set to null",
+			patch:     `{"spec":{"machineType":null}}`,
+			patchType: "merge",
+			check: func(ig *api.InstanceGroup) bool {
+				return ig.Spec.MachineType == "" && fi.Int32Value(ig.Spec.MaxSize) == 3
+			},
+		},
+		{
+			name:      "strategic sets a field",
+			patch:     `{"spec":{"machineType":"m5.large"}}`,
+			patchType: "strategic",
+			check: func(ig *api.InstanceGroup) bool {
+				return ig.Spec.MachineType == "m5.large"
+			},
+		},
+		{
+			name:      "unknown patch type",
+			patch:     `{}`,
+			patchType: "json",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid patch",
+			patch:     `{`,
+			patchType: "merge",
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		ig := &api.InstanceGroup{}
+		ig.ObjectMeta.Name = "nodes"
+		ig.Spec.MachineType = "t2.medium"
+		ig.Spec.MaxSize = fi.Int32(3)
+
+		err := applySpecPatch(ig, tc.patch, tc.patchType)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if ig.ObjectMeta.Name != "nodes" || !tc.check(ig) {
+			t.Errorf("%s: unexpected result %+v", tc.name, ig)
+		}
+	}
+}
@@ -10,9 +10,12 @@ func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
 		DataSourcesMap: map[string]*schema.Resource{
 			"kops_cloud_resources": dataSourceKopsCloudResources(),
+			"kops_template":        dataSourceKopsTemplate(),
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"kops_cluster": resourceKopsCluster(),
+			"kops_cluster":          resourceKopsCluster(),
+			"kops_cluster_manifest": resourceKopsClusterManifest(),
+			"kops_etcd_backup":      resourceKopsEtcdBackup(),
 		},
 	}
 }
@@ -4,19 +4,28 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/apis/kops/registry"
+	"k8s.io/kops/pkg/client/simple"
 	"k8s.io/kops/pkg/client/simple/vfsclientset"
+	"k8s.io/kops/pkg/cloudinstances"
 	commands "k8s.io/kops/pkg/commands"
+	"k8s.io/kops/pkg/instancegroups"
+	"k8s.io/kops/pkg/kopscodecs"
 	"k8s.io/kops/pkg/kubeconfig"
-	"k8s.io/kops/pkg/resources"
 	ops "k8s.io/kops/pkg/resources/ops"
 	"k8s.io/kops/pkg/validation"
 	"k8s.io/kops/upup/pkg/fi"
@@ -36,8 +45,12 @@ func resourceKopsCluster() *schema.Resource {
 		Update: resourceKopsUpdate,
 		Delete: resourceKopsDelete,
 		Schema: kopsSchema(),
+		// Checked before each step of the apply, rolling update and delete. kops can't cancel a step
+		// once started, so an instance group update or resource deletion can overrun them
 		Timeouts: &schema.ResourceTimeout{
-			Create: schema.DefaultTimeout(2 * time.Minute),
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
 		},
 	}
 
@@ -46,6 +59,34 @@ func resourceKopsCluster() *schema.Resource {
 //Sourced:k8s.io/kops/
 func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutCreate))
+	if err := applyKopsCluster(d, deadline); err != nil {
+		return err
+	}
+
+	// The cluster is up, so failing here would only taint it. Leaving the mappings out of state makes
+	// the next plan show them and write the ConfigMap again, as it does after a partial phase
+	if authenticatorMappingsChanged(d) {
+		var err error
+		if isPartialPhase(cloudup.Phase(fmt.Sprint(d.Get("phase")))) {
+			err = fmt.Errorf("phase %s leaves no api to write to", d.Get("phase"))
+		} else {
+			err = writeAuthenticatorConfigMap(d, deadline)
+		}
+		if err != nil {
+			log.Printf("[WARN] Cannot write aws-iam-authenticator ConfigMap of %s, it is written on the next apply: %v", d.Id(), err)
+			d.Set("authentication_role_mapping", nil)
+			d.Set("authentication_user_mapping", nil)
+		}
+	}
+
+	return resourceKopsRead(d, meta)
+}
+
+// applyKopsCluster builds the cluster from the configuration, then saves and applies it. Update
+// reuses it, so everything it runs shares the one deadline
+func applyKopsCluster(d *schema.ResourceData, deadline time.Time) error {
+
 	var (
 		err                        error
 		anonymousAuth              bool
@@ -89,6 +130,11 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
 	cloud := fmt.Sprint(d.Get("cloud"))
+	switch cloud {
+	case "aws", "gce", "digitalocean", "openstack":
+	default:
+		return fmt.Errorf("unsupported cloud %q", cloud)
+	}
 	networking := fmt.Sprint(d.Get("networking"))
 	cluster := &api.Cluster{}
 	clusterName := fmt.Sprint(d.Get("name"))
@@ -134,7 +180,10 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 	topology := fmt.Sprint(d.Get("topology"))
 	validateOnCreation := d.Get("validate_on_creation").(bool)
+
 	networkID := fmt.Sprint(d.Get("network_id"))
+	project := fmt.Sprint(d.Get("project"))
+	region := fmt.Sprint(d.Get("region"))
 
 	cluster.ObjectMeta.Name = clusterName
 	cluster.Spec = api.ClusterSpec{
@@ -152,9 +201,63 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 		cluster.Spec.NetworkID = networkID
 	}
 
-	cluster.Spec.IAM = &api.IAMSpec{
-		AllowContainerRegistry: true,
-		Legacy:                 false,
+	switch cloud {
+	case "aws":
+		cluster.Spec.IAM = expandIAM(d.Get("iam").(*schema.Set).List())
+		if policies := expandAdditionalPolicies(d); len(policies) != 0 {
+			cluster.Spec.AdditionalPolicies = &policies
+		}
+	case "gce":
+		if project == "" {
+			return fmt.Errorf("project is required when cloud is gce")
+		}
+		if topology != api.TopologyPublic {
+			return fmt.Errorf("gce supports topology='public' only")
+		}
+		cluster.Spec.Project = project
+	case "digitalocean":
+		if topology != api.TopologyPublic {
+			return fmt.Errorf("digitalocean supports topology='public' only")
+		}
+		if image == "" {
+			image = "ubuntu-16-04-x64"
+		}
+	case "openstack":
+		if config := expandOpenstackConfiguration(d.Get("openstack").(*schema.Set).List()); config != nil {
+			cluster.Spec.CloudConfig = &api.CloudConfiguration{
+				Openstack: config,
+			}
+		}
+	}
+	if cloud != "aws" && apiSSLCertificate != "" {
+		return fmt.Errorf("api_ssl_certificate is only supported on aws")
+	}
+	if cloud != "aws" {
+		if d.Get("iam").(*schema.Set).Len() != 0 || len(expandAdditionalPolicies(d)) != 0 {
+			return fmt.Errorf("iam and additional policies are only supported on aws")
+		}
+		for _, role := range instanceGroupRoles {
+			if _, ok := d.GetOk(role + "_iam_instance_profile"); ok {
+				return fmt.Errorf("%s_iam_instance_profile is only supported on aws", role)
+			}
+		}
+	}
+
+	// Each role falls back to image, and from there to the channel default
+	masterImage := image
+	if v, ok := d.GetOk("master_image"); ok {
+		masterImage = v.(string)
+	}
+	nodeImage := image
+	if v, ok := d.GetOk("node_image"); ok {
+		nodeImage = v.(string)
+	}
+	bastionImage := image
+	if v, ok := d.GetOk("bastion_image"); ok {
+		bastionImage = v.(string)
+	}
+	if cloud == "openstack" && (masterImage == "" || nodeImage == "" || (bastion && bastionImage == "")) {
+		return fmt.Errorf("image is required when cloud is openstack")
 	}
 
 	if len(cloudLabels) != 0 {
@@ -171,6 +274,18 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("unknown authorization mode %q", authorization)
 	}
 
+	cluster.Spec.Authentication = expandAuthentication(d.Get("authentication").(*schema.Set).List())
+	if cluster.Spec.Authentication != nil && cluster.Spec.Authentication.Aws != nil && cloud != "aws" {
+		return fmt.Errorf("authentication type aws is only supported on aws")
+	}
+	roleMappings := d.Get("authentication_role_mapping").([]interface{})
+	userMappings := d.Get("authentication_user_mapping").([]interface{})
+	if len(roleMappings) != 0 || len(userMappings) != 0 {
+		if cluster.Spec.Authentication == nil || cluster.Spec.Authentication.Aws == nil {
+			return fmt.Errorf("authentication mappings require authentication type aws")
+		}
+	}
+
 	if kubeDNS != "" {
 		cluster.Spec.KubeDNS = &api.KubeDNSConfig{}
 		cluster.Spec.KubeDNS.Provider = kubeDNS
@@ -238,13 +353,22 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 		}
 
 		for _, subnetZone := range subnetZones {
-			if _, value := keys[subnetZone]; !value {
-				keys[subnetZone] = true
-				cluster.Spec.Subnets = append(cluster.Spec.Subnets, api.ClusterSubnetSpec{
-					Name: subnetZone,
+			subnetName := subnetNameForZone(cloud, subnetZone)
+			if _, value := keys[subnetName]; !value {
+				keys[subnetName] = true
+				subnet := api.ClusterSubnetSpec{
+					Name: subnetName,
 					Zone: subnetZone,
 					Type: api.SubnetTypePublic,
-				})
+				}
+				// GCE subnets are regional, the zone is set on the instance groups instead
+				if cloud == "gce" {
+					subnet.Zone = ""
+					subnet.Region = subnetName
+				} else if region != "" {
+					subnet.Region = region
+				}
+				cluster.Spec.Subnets = append(cluster.Spec.Subnets, subnet)
 			}
 		}
 
@@ -257,9 +381,10 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 			if _, value := keys[subnetZone]; !value {
 				keys[subnetZone] = true
 				cluster.Spec.Subnets = append(cluster.Spec.Subnets, api.ClusterSubnetSpec{
-					Name: subnetZone,
-					Zone: subnetZone,
-					Type: api.SubnetTypePrivate,
+					Name:   subnetZone,
+					Zone:   subnetZone,
+					Region: region,
+					Type:   api.SubnetTypePrivate,
 				})
 			}
 		}
@@ -271,9 +396,10 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 				continue
 			}
 			subnet := api.ClusterSubnetSpec{
-				Name: "utility-" + s.Name,
-				Zone: s.Zone,
-				Type: api.SubnetTypeUtility,
+				Name:   "utility-" + s.Name,
+				Zone:   s.Zone,
+				Region: s.Region,
+				Type:   api.SubnetTypeUtility,
 			}
 
 			utilitySubnets = append(utilitySubnets, subnet)
@@ -284,17 +410,24 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 			bastionGroup := &api.InstanceGroup{}
 			bastionGroup.Spec.Role = api.InstanceGroupRoleBastion
 			bastionGroup.ObjectMeta.Name = "bastions"
-			bastionGroup.Spec.Image = image
-
-			_, err = clientset.InstanceGroupsFor(cluster).Create(bastionGroup)
-			if err != nil {
-				return err
+			bastionGroup.Spec.Image = bastionImage
+			expandRootVolume(d, "bastion", &bastionGroup.Spec)
+			expandInstanceProfile(d, "bastion", &bastionGroup.Spec)
+			for _, s := range utilitySubnets {
+				bastionGroup.Spec.Subnets = append(bastionGroup.Spec.Subnets, s.Name)
 			}
 
 			cluster.Spec.Topology.Bastion = &api.BastionSpec{
 				BastionPublicName: "bastion." + clusterName,
 			}
 
+			if l := d.Get("bastion_config").(*schema.Set).List(); len(l) != 0 {
+				if _, ok := d.GetOk("ssh_access"); ok && len(l[0].(map[string]interface{})["allowed_cidrs"].([]interface{})) != 0 {
+					return fmt.Errorf("set either ssh_access or bastion_config allowed_cidrs, not both")
+				}
+				expandBastion(l[0].(map[string]interface{}), cluster, bastionGroup)
+			}
+
 			instanceGroups = append(instanceGroups, bastionGroup)
 
 		}
@@ -303,6 +436,10 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("invalid topology %s", topology)
 	}
 
+	if !bastion && d.Get("bastion_config").(*schema.Set).Len() != 0 {
+		return fmt.Errorf("bastion_config requires bastion = true")
+	}
+
 	cluster.Spec.Topology.DNS = &api.DNSSpec{}
 	if dns == "private" {
 		cluster.Spec.Topology.DNS.Type = api.DNSTypePrivate
@@ -349,33 +486,59 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 		cluster.Spec.API.LoadBalancer.SSLCertificate = apiSSLCertificate
 	}
 
-	// Create master ig(s)
-	for i := 0; i < len(masterZones); i++ {
+	// A previous create may have failed after writing to the state store.
+	// Terraform has no ID for it, so pick it up and resume instead of failing
+	existing, err := getExistingCluster(clientset, clusterName)
+	if err != nil {
+		return err
+	}
+	var storedGroups []api.InstanceGroup
+	if existing != nil {
+		list, err := clientset.InstanceGroupsFor(existing).List(metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot get InstanceGroups for %q: %v", clusterName, err)
+		}
+		storedGroups = list.Items
+	}
 
-		zone := masterZones[i%len(masterZones)]
-		name := zone
+	// Create master ig(s), one per master so each gets its own etcd member. The etcd members of an
+	// existing cluster can't be added or removed here, that needs kops and etcd-manager
+	masterCount := len(masterZones) * int(fi.Int32Value(masterPerZone))
+	storedCount := storedMasterCount(storedGroups)
+	if storedCount == 0 && masterCount%2 == 0 {
+		return fmt.Errorf("master_zones times master_per_zone must be odd for etcd quorum, got %d masters", masterCount)
+	}
+	if storedCount != 0 && storedCount != masterCount {
+		return fmt.Errorf("cannot change the number of masters of cluster %q from %d to %d, add or remove etcd members with kops first", clusterName, storedCount, masterCount)
+	}
+	for _, g := range masterGroups(masterZones, int(fi.Int32Value(masterPerZone)), legacyMasterLayout(storedGroups)) {
 
 		master := &api.InstanceGroup{}
-		master.ObjectMeta.Name = "master-" + name
+		master.ObjectMeta.Name = g.name
 		master.Spec = api.InstanceGroupSpec{
 			AssociatePublicIP: fi.Bool(associatePublicIP),
-			Image:             image,
+			Image:             masterImage,
 			MachineType:       masterSize,
 			Role:              api.InstanceGroupRoleMaster,
 			RootVolumeSize:    masterVolumeSize,
-			MaxSize:           masterPerZone,
-			MinSize:           masterPerZone,
-			Subnets:           []string{masterZones[i%len(masterZones)]},
+			MaxSize:           fi.Int32(g.size),
+			MinSize:           fi.Int32(g.size),
+			Subnets:           []string{subnetNameForZone(cloud, g.zone)},
 		}
+		if cloud == "gce" {
+			master.Spec.Zones = []string{g.zone}
+		}
+		expandRootVolume(d, "master", &master.Spec)
+		expandInstanceProfile(d, "master", &master.Spec)
 
 		masters = append(masters, master)
 		instanceGroups = append(instanceGroups, master)
 
-		_, err = clientset.InstanceGroupsFor(cluster).Create(master)
-		if err != nil {
-			return err
-		}
+	}
 
+	etcdConfigs, err := etcdClusterConfigs(d.Get("etcd_cluster").(*schema.Set).List())
+	if err != nil {
+		return err
 	}
 
 	for _, etcdClusterName := range cloudup.EtcdClusters {
@@ -394,6 +557,12 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 			etcdCluster.Members = append(etcdCluster.Members, etcdMember)
 		}
 
+		if m, ok := etcdConfigs[etcdClusterName]; ok {
+			if err := expandEtcdClusterSpec(m, etcdCluster); err != nil {
+				return fmt.Errorf("error in etcd_cluster %q: %v", etcdClusterName, err)
+			}
+		}
+
 		cluster.Spec.EtcdClusters = append(cluster.Spec.EtcdClusters, etcdCluster)
 	}
 
@@ -401,7 +570,7 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 	nodes.ObjectMeta.Name = "nodes"
 	nodes.Spec = api.InstanceGroupSpec{
 		AssociatePublicIP: fi.Bool(associatePublicIP),
-		Image:             image,
+		Image:             nodeImage,
 		MachineType:       nodeSize,
 		MaxSize:           nodeMaxSize,
 		MinSize:           nodeMinSize,
@@ -409,85 +578,143 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 		RootVolumeSize:    nodeVolumeSize,
 		Subnets:           nodeZones,
 	}
+	if cloud == "gce" {
+		nodes.Spec.Subnets = []string{subnetNameForZone(cloud, nodeZones[0])}
+		nodes.Spec.Zones = nodeZones
+	}
+	expandRootVolume(d, "node", &nodes.Spec)
+	expandInstanceProfile(d, "node", &nodes.Spec)
+
+	if d.Get("node_group_per_zone").(bool) {
+		// One group per zone so the cluster-autoscaler can scale each zone on its own,
+		// with the sizes spread as evenly as possible
+		for i, zone := range nodeZones {
+			zoneNodes := nodes.DeepCopy()
+			zoneNodes.ObjectMeta.Name = "nodes-" + zone
+			zoneNodes.Spec.Subnets = []string{subnetNameForZone(cloud, zone)}
+			if cloud == "gce" {
+				zoneNodes.Spec.Zones = []string{zone}
+			}
+			zoneNodes.Spec.MinSize = fi.Int32(spreadSize(fi.Int32Value(nodeMinSize), len(nodeZones), i))
+			zoneNodes.Spec.MaxSize = fi.Int32(spreadSize(fi.Int32Value(nodeMaxSize), len(nodeZones), i))
+			instanceGroups = append(instanceGroups, zoneNodes)
+		}
+	} else {
+		instanceGroups = append(instanceGroups, nodes)
+	}
 
-	instanceGroups = append(instanceGroups, nodes)
+	// Patches go last so they can override anything set above
+	patchType := fmt.Sprint(d.Get("spec_patch_type"))
+	if v, ok := d.GetOk("cluster_spec_patch"); ok {
+		if err := applySpecPatch(cluster, v.(string), patchType); err != nil {
+			return fmt.Errorf("error applying cluster_spec_patch: %v", err)
+		}
+	}
+	for igName, v := range d.Get("instance_group_spec_patch").(map[string]interface{}) {
+		// With node_group_per_zone a patch for nodes goes to every nodes-<zone> group
+		var patched []*api.InstanceGroup
+		for _, g := range instanceGroups {
+			if g.ObjectMeta.Name == igName || (igName == "nodes" && g.Spec.Role == api.InstanceGroupRoleNode) {
+				patched = append(patched, g)
+			}
+		}
+		if len(patched) == 0 {
+			return fmt.Errorf("instance_group_spec_patch: unknown instance group %q", igName)
+		}
+		for _, ig := range patched {
+			if err := applySpecPatch(ig, fmt.Sprint(v), patchType); err != nil {
+				return fmt.Errorf("error applying instance_group_spec_patch for %q: %v", ig.ObjectMeta.Name, err)
+			}
+		}
+	}
 
-	_, err = clientset.InstanceGroupsFor(cluster).Create(nodes)
-	if err != nil {
-		return err
+	// Hibernation goes after the patches so it has the final say on sizes. The masters keep running,
+	// so the state store, etcd and the api stay available while hibernated. Waking needs nothing more,
+	// the groups are saved with their configured sizes and without the annotations
+	hibernated := d.Get("hibernated").(bool)
+	if hibernated {
+		log.Printf("[INFO] Hibernating Kops Cluster %s", clusterName)
+		for _, ig := range instanceGroups {
+			if ig.Spec.Role != api.InstanceGroupRoleMaster {
+				hibernateInstanceGroup(ig)
+			}
+		}
 	}
 
-	sshCredentialStore, err := clientset.SSHCredentialStore(cluster)
+	phase := cloudup.Phase(fmt.Sprint(d.Get("phase")))
+
+	lifecycleOverrides, err := expandLifecycleOverrides(d.Get("lifecycle_overrides").(map[string]interface{}))
 	if err != nil {
 		return err
 	}
 
+	// Read the key before anything is written to the state store so a bad
+	// path doesn't leave a half created cluster behind
 	f := utils.ExpandPath(d.Get("ssh_public_key").(string))
 	pubKey, err := ioutil.ReadFile(f)
 	if err != nil {
 		return fmt.Errorf("error reading SSH key file %q: %v", f, err)
 	}
-	err = sshCredentialStore.AddSSHPublicKey(fi.SecretNameSSHPrimary, pubKey)
-	if err != nil {
-		return fmt.Errorf("error adding SSH public key: %v", err)
-	}
-	if err := cloudup.PerformAssignments(cluster); err != nil {
-		return err
-	}
 
-	_, err = clientset.CreateCluster(cluster)
-	if err != nil {
+	if err := cloudup.PerformAssignments(cluster); err != nil {
 		return err
 	}
 
-	apply := &cloudup.ApplyClusterCmd{
-		Cluster:        cluster,
-		Clientset:      clientset,
-		TargetName:     cloudup.TargetDirect,
-		InstanceGroups: instanceGroups,
+	if existing != nil {
+		log.Printf("[INFO] Kops Cluster %s already exists in state store, resuming create", clusterName)
+		cluster.ObjectMeta.CreationTimestamp = existing.ObjectMeta.CreationTimestamp
 	}
 
-	err = apply.Run()
-	if err != nil {
-		return err
+	// Only a fresh create is rolled back, an update or resumed create would lose a cluster
+	// that was there before. Files already in the state store are left alone
+	configBase := registryBase.Join(clusterName)
+	rollback := d.Get("rollback_on_failure").(bool) && d.Id() == "" && existing == nil
+	var storedFiles map[string]bool
+	if rollback {
+		if storedFiles, err = stateStoreFiles(configBase); err != nil {
+			return err
+		}
 	}
 
-	keyStore, err := clientset.KeyStore(cluster)
+	err = saveAndApplyCluster(clientset, cluster, instanceGroups, pubKey, existing != nil, lifecycleOverrides, phase, deadline)
 	if err != nil {
+		if rollback {
+			log.Printf("[INFO] Rolling back Kops Cluster %s", clusterName)
+			if rerr := rollbackCluster(cluster, configBase, storedFiles, time.Now().Add(d.Timeout(schema.TimeoutDelete))); rerr != nil {
+				log.Printf("[WARN] Rolling back Kops Cluster %s failed, clean up with kops delete cluster: %v", clusterName, rerr)
+			}
+		}
 		return err
 	}
 
-	secretStore, err := clientset.SecretStore(cluster)
-	if err != nil {
-		return err
+	// The network and security phases leave no masters to export credentials for or validate
+	if isPartialPhase(phase) {
+		log.Printf("[INFO] Applied phase %s of Kops Cluster %s", phase, clusterName)
+		d.SetId(clusterName)
+		return nil
 	}
 
-	conf, err := kubeconfig.BuildKubecfg(cluster, keyStore, secretStore, &commands.CloudDiscoveryStatusStore{})
-
-	if err != nil {
+	if err := writeKubeconfig(clientset, cluster); err != nil {
 		return err
 	}
-
-	conf.WriteKubecfg()
 	d.SetId(clusterName)
 
 	// Buggy ¯\_(ツ)_/¯
-	if validateOnCreation {
+	// A hibernated cluster has no nodes to validate
+	if validateOnCreation && !hibernated {
 		list, err := clientset.InstanceGroupsFor(cluster).List(metav1.ListOptions{})
 		if err != nil {
 			return fmt.Errorf("cannot get InstanceGroups")
 		}
 
-		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			clientcmd.NewDefaultClientConfigLoadingRules(),
-			&clientcmd.ConfigOverrides{CurrentContext: clusterName}).ClientConfig()
+		_, k8sClient, err := kubernetesClient(clusterName)
 		if err != nil {
-			return fmt.Errorf("Cannot load kubecfg settings for %q: %v", clusterName, err)
+			return err
 		}
 
-		k8sClient, err := kubernetes.NewForConfig(config)
+		timeout, err := remainingTime(deadline, "validating cluster")
 		if err != nil {
-			return fmt.Errorf("Cannot build kubernetes api client for %q: %v", clusterName, err)
+			return err
 		}
 
 		validateClusterState := &resource.StateChangeConf{
@@ -506,7 +733,7 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 				return result, "Ready", nil
 
 			},
-			Timeout:                   8 * time.Minute,
+			Timeout:                   timeout,
 			MinTimeout:                5 * time.Second,
 			ContinuousTargetOccurence: 2,
 		}
@@ -517,10 +744,424 @@ func resourceKopsCreate(d *schema.ResourceData, meta interface{}) error {
 
 	}
 
-	return resourceKopsRead(d, meta)
+	return nil
 
 }
 
+// getExistingCluster returns the cluster stored under name, or nil if the state store has none
+func getExistingCluster(clientset simple.Clientset, name string) (*api.Cluster, error) {
+	cluster, err := clientset.GetCluster(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading cluster %q from state store: %v", name, err)
+	}
+	return cluster, nil
+}
+
+// readCompletedCluster returns the completed cluster spec the last apply stored next to the cluster,
+// the same as kops get cluster --full, or nil if the cluster was never applied
+func readCompletedCluster(cluster *api.Cluster) (*api.Cluster, error) {
+
+	configBase, err := registry.ConfigBase(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	fullCluster := &api.Cluster{}
+	err = registry.ReadConfigDeprecated(configBase.Join(registry.PathClusterCompleted), fullCluster)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading full spec of cluster %q: %v", cluster.ObjectMeta.Name, err)
+	}
+	return fullCluster, nil
+}
+
+// rollbackCluster removes what a failed create left behind: the cloud resources tagged for the
+// cluster, then the files under configBase that were not in storedFiles before the create
+func rollbackCluster(cluster *api.Cluster, configBase vfs.Path, storedFiles map[string]bool, deadline time.Time) error {
+
+	if err := deleteClusterResources(cluster, deadline); err != nil {
+		return err
+	}
+
+	files, err := configBase.ReadTree()
+	if err != nil {
+		return fmt.Errorf("error listing state store %q: %v", configBase.Path(), err)
+	}
+	for _, p := range addedStateStoreFiles(storedFiles, files) {
+		log.Printf("[INFO] Removing %s from state store", p.Path())
+		if err := p.Remove(); err != nil {
+			return fmt.Errorf("error removing %q: %v", p.Path(), err)
+		}
+	}
+	return nil
+}
+
+// saveAndApplyCluster writes the cluster, its instance groups and ssh key, if any, to the state store and
+// runs the apply. When resume is set objects left by an earlier failed create are updated in place, and
+// provider managed instance groups missing from instanceGroups are deleted after a successful apply.
+// lifecycleOverrides, if any, change which tasks the apply syncs, e.g. to leave IAM to another tool, and
+// a non empty phase limits the apply to that phase. Tasks are retried until deadline
+func saveAndApplyCluster(clientset simple.Clientset, cluster *api.Cluster, instanceGroups []*api.InstanceGroup, pubKey []byte, resume bool, lifecycleOverrides map[string]fi.Lifecycle, phase cloudup.Phase, deadline time.Time) error {
+
+	var err error
+
+	if resume {
+		_, err = clientset.UpdateCluster(cluster, nil)
+	} else {
+		_, err = clientset.CreateCluster(cluster)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, ig := range instanceGroups {
+		if err := createOrUpdateInstanceGroup(clientset, cluster, ig); err != nil {
+			return err
+		}
+	}
+
+	if pubKey != nil {
+		sshCredentialStore, err := clientset.SSHCredentialStore(cluster)
+		if err != nil {
+			return err
+		}
+
+		err = sshCredentialStore.AddSSHPublicKey(fi.SecretNameSSHPrimary, pubKey)
+		if err != nil {
+			return fmt.Errorf("error adding SSH public key: %v", err)
+		}
+	}
+
+	timeout, err := remainingTime(deadline, "applying cluster")
+	if err != nil {
+		return err
+	}
+
+	apply := &cloudup.ApplyClusterCmd{
+		Cluster:            cluster,
+		Clientset:          clientset,
+		TargetName:         cloudup.TargetDirect,
+		InstanceGroups:     instanceGroups,
+		LifecycleOverrides: lifecycleOverrides,
+		Phase:              phase,
+		MaxTaskDuration:    timeout,
+	}
+
+	if err := apply.Run(); err != nil {
+		return err
+	}
+
+	// Instance groups no longer wanted, e.g. the bastion once disabled, are drained and deleted
+	// along with their cloud resources once the apply brought up the rest of the cluster
+	if !resume || isPartialPhase(phase) {
+		return nil
+	}
+
+	list, err := clientset.InstanceGroupsFor(cluster).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get InstanceGroups for %q: %v", cluster.ObjectMeta.Name, err)
+	}
+	for _, ig := range removedInstanceGroups(list.Items, instanceGroups) {
+		if err := drainAndDeleteInstanceGroup(clientset, cluster, ig, deadline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeKubeconfig adds the cluster's context to the local kubeconfig, the same as kops export kubecfg
+func writeKubeconfig(clientset simple.Clientset, cluster *api.Cluster) error {
+
+	keyStore, err := clientset.KeyStore(cluster)
+	if err != nil {
+		return err
+	}
+
+	secretStore, err := clientset.SecretStore(cluster)
+	if err != nil {
+		return err
+	}
+
+	conf, err := kubeconfig.BuildKubecfg(cluster, keyStore, secretStore, &commands.CloudDiscoveryStatusStore{})
+
+	if err != nil {
+		return err
+	}
+
+	return conf.WriteKubecfg()
+}
+
+// deleteKopsCluster removes the cluster's cloud resources, its state store objects and kubeconfig context
+func deleteKopsCluster(clientset simple.Clientset, cluster *api.Cluster, deadline time.Time) error {
+
+	name := cluster.ObjectMeta.Name
+
+	if err := deleteClusterResources(cluster, deadline); err != nil {
+		return err
+	}
+
+	{
+		err := clientset.DeleteCluster(cluster)
+		if err != nil {
+			log.Printf("[DEBUG] Received error: %#v", err)
+			return err
+		}
+
+	}
+
+	conf := kubeconfig.NewKubeconfigBuilder()
+	conf.Context = name
+
+	if err := conf.DeleteKubeConfig(); err != nil {
+		log.Printf("[DEBUG] Received error: %#v", err)
+	}
+	return nil
+}
+
+// deleteClusterResources deletes the cloud resources kops finds for the cluster, leaving shared ones alone
+func deleteClusterResources(cluster *api.Cluster, deadline time.Time) error {
+
+	cloud, clusterResources, err := listClusterResources(cluster, false)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range flattenDeletePreview(clusterResources) {
+		log.Printf("[INFO] Deleting %s %s (%s)", r["type"], r["name"], r["id"])
+	}
+
+	// DeleteResources retries until everything is gone or it stops making progress, it can't be bounded
+	if _, err := remainingTime(deadline, "deleting cloud resources"); err != nil {
+		return err
+	}
+	return ops.DeleteResources(cloud, clusterResources)
+}
+
+// rollingUpdateCluster replaces any instances whose launch configuration no longer matches the
+// instance group spec, the same as kops rolling-update cluster --yes. When roles are given only
+// instance groups with those roles are updated, the same as --instance-group-roles. Nodes are drained
+// and the cluster validated unless cloudOnly is set, the same as --cloudonly. Instance groups are
+// updated one at a time and the deadline is checked before each, a group that is being updated is
+// not interrupted
+func rollingUpdateCluster(clientset simple.Clientset, cluster *api.Cluster, roles []api.InstanceGroupRole, cloudOnly bool, deadline time.Time) error {
+
+	clusterName := cluster.ObjectMeta.Name
+
+	list, err := clientset.InstanceGroupsFor(cluster).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get InstanceGroups for %q: %v", clusterName, err)
+	}
+
+	var instanceGroups []*api.InstanceGroup
+	for i := range list.Items {
+		instanceGroups = append(instanceGroups, &list.Items[i])
+	}
+
+	cloud, err := cloudup.BuildCloud(cluster)
+	if err != nil {
+		return err
+	}
+
+	var (
+		nodes        []v1.Node
+		clientConfig clientcmd.ClientConfig
+		k8sClient    kubernetes.Interface
+	)
+	if !cloudOnly {
+		clientConfig, k8sClient, err = kubernetesClient(clusterName)
+		if err != nil {
+			return fmt.Errorf("%v, set rolling_update_cloud_only to replace instances without draining or validation", err)
+		}
+		nodeList, err := k8sClient.CoreV1().Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot reach kubernetes api for %q, set rolling_update_cloud_only to replace instances without draining or validation: %v", clusterName, err)
+		}
+		nodes = nodeList.Items
+	}
+
+	groups, err := cloud.GetCloudGroups(cluster, instanceGroups, false, nodes)
+	if err != nil {
+		return err
+	}
+
+	if len(roles) == 0 {
+		roles = []api.InstanceGroupRole{api.InstanceGroupRoleBastion, api.InstanceGroupRoleMaster, api.InstanceGroupRoleNode}
+	}
+
+	// kops updates bastions, then masters, then nodes
+	for _, role := range []api.InstanceGroupRole{api.InstanceGroupRoleBastion, api.InstanceGroupRoleMaster, api.InstanceGroupRoleNode} {
+		if !roleIn(roles, role) {
+			continue
+		}
+		names := make([]string, 0, len(groups))
+		for name, group := range groups {
+			if group.InstanceGroup.Spec.Role == role {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			timeout, err := remainingTime(deadline, "rolling update of "+name)
+			if err != nil {
+				return err
+			}
+
+			rollingUpdate := &instancegroups.RollingUpdateCluster{
+				Cloud:             cloud,
+				MasterInterval:    5 * time.Minute,
+				NodeInterval:      4 * time.Minute,
+				BastionInterval:   5 * time.Minute,
+				K8sClient:         k8sClient,
+				ClientConfig:      clientConfig,
+				FailOnDrainError:  true,
+				FailOnValidate:    true,
+				CloudOnly:         cloudOnly,
+				ClusterName:       clusterName,
+				ValidationTimeout: timeout,
+			}
+			if err := rollingUpdate.RollingUpdate(map[string]*cloudinstances.CloudInstanceGroup{name: groups[name]}, cluster, list); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// roleIn reports whether role is in roles
+func roleIn(roles []api.InstanceGroupRole, role api.InstanceGroupRole) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// createOrUpdateInstanceGroup saves ig, replacing any instance group of the same name, and labels it as
+// managed by the provider
+func createOrUpdateInstanceGroup(clientset simple.Clientset, cluster *api.Cluster, ig *api.InstanceGroup) error {
+
+	if ig.ObjectMeta.Labels == nil {
+		ig.ObjectMeta.Labels = make(map[string]string)
+	}
+	ig.ObjectMeta.Labels[managedByLabel] = managedByValue
+
+	existing, err := clientset.InstanceGroupsFor(cluster).Get(ig.ObjectMeta.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error reading InstanceGroup %q: %v", ig.ObjectMeta.Name, err)
+	}
+
+	if err == nil && existing != nil {
+		ig.ObjectMeta.CreationTimestamp = existing.ObjectMeta.CreationTimestamp
+		_, err = clientset.InstanceGroupsFor(cluster).Update(ig)
+	} else {
+		_, err = clientset.InstanceGroupsFor(cluster).Create(ig)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving InstanceGroup %q: %v", ig.ObjectMeta.Name, err)
+	}
+	return nil
+}
+
+// drainAndDeleteInstanceGroup drains the nodes of ig, then deletes it along with its cloud resources.
+// Nodes that cannot be drained, e.g. because the api is unreachable, stop the delete
+func drainAndDeleteInstanceGroup(clientset simple.Clientset, cluster *api.Cluster, ig *api.InstanceGroup, deadline time.Time) error {
+
+	clusterName := cluster.ObjectMeta.Name
+
+	cloud, err := cloudup.BuildCloud(cluster)
+	if err != nil {
+		return err
+	}
+
+	var nodes []v1.Node
+	clientConfig, k8sClient, apiErr := kubernetesClient(clusterName)
+	if apiErr == nil {
+		var nodeList *v1.NodeList
+		nodeList, apiErr = k8sClient.CoreV1().Nodes().List(metav1.ListOptions{})
+		if apiErr == nil {
+			nodes = nodeList.Items
+		}
+	}
+
+	groups, err := cloud.GetCloudGroups(cluster, []*api.InstanceGroup{ig}, false, nodes)
+	if err != nil {
+		return err
+	}
+
+	rollingUpdate := &instancegroups.RollingUpdateCluster{
+		Cloud:            cloud,
+		K8sClient:        k8sClient,
+		ClientConfig:     clientConfig,
+		FailOnDrainError: true,
+		ClusterName:      clusterName,
+	}
+
+	for _, group := range groups {
+		members := append(append([]*cloudinstances.CloudInstanceGroupMember{}, group.Ready...), group.NeedUpdate...)
+		if len(members) == 0 {
+			continue
+		}
+		if apiErr != nil {
+			return fmt.Errorf("cannot drain InstanceGroup %q, kubernetes api for %q unreachable: %v", ig.ObjectMeta.Name, clusterName, apiErr)
+		}
+
+		r, err := instancegroups.NewRollingUpdateInstanceGroup(cloud, group)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			// Instances that never registered have nothing to drain
+			if member.Node == nil {
+				continue
+			}
+			if _, err := remainingTime(deadline, "draining node "+member.Node.Name); err != nil {
+				return err
+			}
+			log.Printf("[INFO] Draining node %s of InstanceGroup %s", member.Node.Name, ig.ObjectMeta.Name)
+			if err := r.DrainNode(member, rollingUpdate); err != nil {
+				return fmt.Errorf("error draining node %q of InstanceGroup %q: %v", member.Node.Name, ig.ObjectMeta.Name, err)
+			}
+		}
+	}
+
+	log.Printf("[INFO] Deleting InstanceGroup %s from Kops Cluster %s", ig.ObjectMeta.Name, clusterName)
+	d := &instancegroups.DeleteInstanceGroup{
+		Cluster:   cluster,
+		Cloud:     cloud,
+		Clientset: clientset,
+	}
+	if err := d.DeleteInstanceGroup(ig); err != nil {
+		return fmt.Errorf("error deleting InstanceGroup %q: %v", ig.ObjectMeta.Name, err)
+	}
+	return nil
+}
+
+// kubernetesClient builds a client for the cluster's kubeconfig context, the one writeKubeconfig adds
+func kubernetesClient(clusterName string) (clientcmd.ClientConfig, kubernetes.Interface, error) {
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: clusterName})
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot load kubecfg settings for %q: %v", clusterName, err)
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot build kubernetes api client for %q: %v", clusterName, err)
+	}
+	return clientConfig, k8sClient, nil
+}
+
 func resourceKopsRead(d *schema.ResourceData, meta interface{}) error {
 
 	name := d.Id()
@@ -542,7 +1183,6 @@ func resourceKopsRead(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	// d.Set("dry_run", false) // need to determine like ^
-	// d.Set("subnets", cluster.Spec.Subnets) // subnets slice
 	// d.Set("target", cluster.Spec.Target) Force new
 	// d.Set("utility_subnets", cluster.Spec.Subnets) // need to find if exist
 
@@ -552,10 +1192,12 @@ func resourceKopsRead(d *schema.ResourceData, meta interface{}) error {
 	if cluster.Spec.API.LoadBalancer != nil && cluster.Spec.API.LoadBalancer.Type == "" {
 		d.Set("api_load_balancer_type", cluster.Spec.API.LoadBalancer.Type)
 	}
-	if cluster.Spec.Authorization.AlwaysAllow != nil {
-		d.Set("authorization", cluster.Spec.Authorization.AlwaysAllow)
-	} else {
-		d.Set("authorization", cluster.Spec.Authorization.RBAC)
+	if cluster.Spec.Authorization != nil {
+		if cluster.Spec.Authorization.RBAC != nil {
+			d.Set("authorization", "RBAC")
+		} else if cluster.Spec.Authorization.AlwaysAllow != nil {
+			d.Set("authorization", "AlwaysAllow")
+		}
 	}
 	if cluster.Spec.CloudLabels != nil {
 		d.Set("cloud_labels", cluster.Spec.CloudLabels)
@@ -563,58 +1205,239 @@ func resourceKopsRead(d *schema.ResourceData, meta interface{}) error {
 
 	d.Set("config", cluster.Spec.ConfigBase) // computed
 	d.Set("dns", strings.ToLower(string(cluster.Spec.Topology.DNS.Type)))
-	d.Set("encrypt_etcd_storage", fi.BoolValue(cluster.Spec.EtcdClusters[0].Members[0].EncryptedVolume))
-	d.Set("etcd_version", cluster.Spec.EtcdClusters[0].Version)
+	etcdConfigs, err := etcdClusterConfigs(d.Get("etcd_cluster").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+	etcdVersion, versionOK, encrypted, encryptedOK := flattenEtcdSettings(cluster.Spec.EtcdClusters, etcdConfigs)
+	if versionOK {
+		d.Set("etcd_version", etcdVersion)
+	}
+	if encryptedOK {
+		d.Set("encrypt_etcd_storage", encrypted)
+	}
+
+	// Only read back the etcd clusters that are configured, the others use the defaults
+	if len(etcdConfigs) != 0 {
+		var etcdClusters []interface{}
+		for _, etcdCluster := range cluster.Spec.EtcdClusters {
+			if m, ok := etcdConfigs[etcdCluster.Name]; ok {
+				etcdClusters = append(etcdClusters, flattenEtcdClusterSpec(etcdCluster, m))
+			}
+		}
+		d.Set("etcd_cluster", etcdClusters)
+	}
 	d.Set("k8s_version", cluster.Spec.KubernetesVersion)
 	d.Set("name", cluster.Name)
 	d.Set("network_cidr", cluster.Spec.NetworkCIDR)
 	d.Set("networking", cluster.Spec.Networking)
 	d.Set("non_masquerade_cidr", cluster.Spec.NonMasqueradeCIDR)
-	d.Set("ssh_access", cluster.Spec.SSHAccess)
+	if _, ok := d.GetOk("ssh_access"); ok {
+		d.Set("ssh_access", cluster.Spec.SSHAccess)
+	}
 	d.Set("state_store", strings.Split(cluster.Spec.ConfigBase, "/")) // Force new
 	d.Set("topology", cluster.Spec.Topology.Masters)
 	d.Set("network_id", cluster.Spec.NetworkID)
+	d.Set("project", cluster.Spec.Project)
+	if cluster.Spec.CloudProvider != "gce" && len(cluster.Spec.Subnets) != 0 {
+		d.Set("region", cluster.Spec.Subnets[0].Region)
+	}
+	if cluster.Spec.CloudConfig != nil && cluster.Spec.CloudConfig.Openstack != nil {
+		d.Set("openstack", flattenOpenstackConfiguration(cluster.Spec.CloudConfig.Openstack))
+	}
+	if d.Get("iam").(*schema.Set).Len() != 0 {
+		d.Set("iam", flattenIAM(cluster.Spec.IAM))
+	}
+	flattenAdditionalPolicies(d, cluster.Spec.AdditionalPolicies)
+	d.Set("authentication", flattenAuthentication(cluster.Spec.Authentication))
+
+	// With aws authentication the mappings come from the ConfigMap in the cluster, so changes made with
+	// kubectl or a ConfigMap that was never written show up as a diff. When the api cannot be reached
+	// the mappings in state are kept
+	roleMappings := d.Get("authentication_role_mapping").([]interface{})
+	userMappings := d.Get("authentication_user_mapping").([]interface{})
+	configMapYaml := ""
+	if cluster.Spec.Authentication != nil && cluster.Spec.Authentication.Aws != nil {
+		live, err := getAuthenticatorConfigMap(name)
+		if err != nil {
+			log.Printf("[WARN] Cannot read aws-iam-authenticator ConfigMap of %s, keeping the mappings in state: %v", name, err)
+		} else if roleMappings, userMappings, err = flattenAuthenticatorConfigMap(live); err != nil {
+			return err
+		}
+
+		configMap, err := authenticatorConfigMap(name, roleMappings, userMappings)
+		if err != nil {
+			return err
+		}
+		b, err := yaml.Marshal(configMap)
+		if err != nil {
+			return fmt.Errorf("error formatting aws-iam-authenticator ConfigMap: %v", err)
+		}
+		configMapYaml = string(b)
+	}
+	d.Set("authentication_role_mapping", roleMappings)
+	d.Set("authentication_user_mapping", userMappings)
+	d.Set("authentication_config_map", configMapYaml)
+
+	// Listing walks every resource type of the cloud provider, so it is left off unless asked for
+	deletePreview := []map[string]interface{}{}
+	if d.Get("enable_delete_preview").(bool) {
+		_, clusterResources, err := listClusterResources(cluster, false)
+		if err != nil {
+			log.Printf("[WARN] Cannot list cloud resources of %q for delete_preview: %v", name, err)
+		} else {
+			deletePreview = flattenDeletePreview(clusterResources)
+		}
+	}
+	d.Set("delete_preview", deletePreview)
+
+	// The apply stores the completed spec, only a cluster that never got through one has none
+	fullCluster, err := readCompletedCluster(cluster)
+	if err != nil {
+		return err
+	}
+	if fullCluster != nil {
+		fullSpec, err := kopscodecs.ToVersionedYaml(fullCluster)
+		if err != nil {
+			return fmt.Errorf("error formatting full spec of cluster %q: %v", name, err)
+		}
+		d.Set("full_spec_yaml", string(fullSpec))
+		d.Set("cluster_subnets", flattenSubnets(fullCluster.Spec.Subnets))
+	} else {
+		d.Set("full_spec_yaml", "")
+		d.Set("cluster_subnets", flattenSubnets(cluster.Spec.Subnets))
+	}
 
 	list, err := clientset.InstanceGroupsFor(cluster).List(metav1.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("cannot get InstanceGroups for %q: %v", cluster.ObjectMeta.Name, err)
 	}
 
+	var masterZones []string
+	masterCount := 0
+	hasBastion := false
+	var nodeZones []string
+	var nodeMinSize, nodeMaxSize int32
+	nodeGroups := 0
+	nodeGroupPerZone := false
+	hibernated := false
+
 	for _, ig := range list.Items {
 
-		// Will need to deal with mult-zoned masters,but values will likely be the same
-		if strings.Contains(ig.Name, "master") {
-			d.Set("image", ig.Spec.Image)
-			d.Set("master_per_zone", ig.Spec.MaxSize)
+		if _, _, ok := hibernatedSizes(&ig); ok && ig.Spec.Role != api.InstanceGroupRoleMaster {
+			hibernated = true
+		}
+
+		switch ig.Spec.Role {
+		case api.InstanceGroupRoleMaster:
+			// Legacy clusters run several masters in one group, so count instances rather than groups
+			masterCount += int(instanceGroupSize(&ig))
+			for _, zone := range instanceGroupZones(&ig) {
+				if !containsString(masterZones, zone) {
+					masterZones = append(masterZones, zone)
+				}
+			}
+			// Masters are created alike, so any of them will do for the shared settings
+			flattenImage(d, "master", ig.Spec.Image)
 			d.Set("master_security_groups", ig.Spec.SecurityGroupOverride)
 			d.Set("master_size", ig.Spec.MachineType)
-			d.Set("	associate_public_ip", ig.Spec.AssociatePublicIP)
+			d.Set("associate_public_ip", fi.BoolValue(ig.Spec.AssociatePublicIP))
 
 			d.Set("master_volume_size", ig.Spec.RootVolumeSize)
-			d.Set("master_zones", ig.Spec.Subnets) // Need to iterate each master
-		}
-		if strings.Contains(ig.Name, "node") {
-			d.Set("node_max_size", ig.Spec.MaxSize)
-			d.Set("node_min_size", ig.Spec.MinSize)
+			flattenRootVolume(d, "master", &ig.Spec)
+			flattenInstanceProfile(d, "master", &ig.Spec)
+
+		case api.InstanceGroupRoleNode:
+			// Node groups added with kops create ig are not part of the configuration
+			if !isProviderNodeGroup(&ig) {
+				continue
+			}
+			// With node_group_per_zone the groups are read back as one, summing their sizes
+			nodeGroups++
+			nodeGroupPerZone = nodeGroupPerZone || ig.ObjectMeta.Name != "nodes"
+			if minSize, maxSize, ok := hibernatedSizes(&ig); ok {
+				nodeMinSize += minSize
+				nodeMaxSize += maxSize
+			} else {
+				nodeMinSize += fi.Int32Value(ig.Spec.MinSize)
+				nodeMaxSize += fi.Int32Value(ig.Spec.MaxSize)
+			}
+			nodeZones = append(nodeZones, instanceGroupZones(&ig)...)
+			flattenImage(d, "node", ig.Spec.Image)
 			d.Set("node_security_groups", ig.Spec.SecurityGroupOverride)
 			d.Set("node_size", ig.Spec.MachineType)
 			d.Set("node_volume_size", ig.Spec.RootVolumeSize)
-			d.Set("node_zones", ig.Spec.Subnets)
-		}
-		if strings.Contains(ig.Name, "bastion") {
-			d.Set("bastion", true)
-		} else {
-			d.Set("bastion", false)
+			flattenRootVolume(d, "node", &ig.Spec)
+			flattenInstanceProfile(d, "node", &ig.Spec)
+
+		case api.InstanceGroupRoleBastion:
+			hasBastion = true
+			flattenImage(d, "bastion", ig.Spec.Image)
+			if l := d.Get("bastion_config").(*schema.Set).List(); len(l) != 0 {
+				d.Set("bastion_config", flattenBastion(cluster, &ig, l[0].(map[string]interface{})))
+			}
+			d.Set("bastion_volume_size", fi.Int32Value(ig.Spec.RootVolumeSize))
+			flattenRootVolume(d, "bastion", &ig.Spec)
+			flattenInstanceProfile(d, "bastion", &ig.Spec)
 		}
 
 	}
 
+	// Keep the configured zone order, the list only differs if zones were added or removed
+	sort.Strings(masterZones)
+	configured := expandStringList(d.Get("master_zones").([]interface{}))
+	sortedConfigured := append([]string{}, configured...)
+	sort.Strings(sortedConfigured)
+	if strings.Join(sortedConfigured, ",") == strings.Join(masterZones, ",") {
+		masterZones = configured
+	}
+	d.Set("master_zones", masterZones)
+	if len(masterZones) != 0 {
+		d.Set("master_per_zone", masterCount/len(masterZones))
+	}
+	d.Set("bastion", hasBastion)
+	d.Set("hibernated", hibernated)
+
+	if nodeGroups != 0 {
+		configured := expandStringList(d.Get("node_zones").([]interface{}))
+		sortedConfigured := append([]string{}, configured...)
+		sort.Strings(sortedConfigured)
+		sort.Strings(nodeZones)
+		if strings.Join(sortedConfigured, ",") == strings.Join(nodeZones, ",") {
+			nodeZones = configured
+		}
+		d.Set("node_zones", nodeZones)
+		d.Set("node_min_size", nodeMinSize)
+		d.Set("node_max_size", nodeMaxSize)
+		d.Set("node_group_per_zone", nodeGroupPerZone)
+	}
+
 	return nil
 }
 
 // time to flatten our cluster Object what fun
 func resourceKopsUpdate(d *schema.ResourceData, meta interface{}) error {
 
+	// The apply, rolling update and ConfigMap write share the update timeout
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutUpdate))
+	partialPhase := isPartialPhase(cloudup.Phase(fmt.Sprint(d.Get("phase"))))
+
+	// The previous mappings stay in state until the ConfigMap is written, so a failure on the way retries it
+	writeMappings := authenticatorMappingsChanged(d)
+	if writeMappings {
+		d.Partial(true)
+		for k := range kopsSchema() {
+			if !containsString(authenticatorMappingAttributes, k) {
+				d.SetPartial(k)
+			}
+		}
+	}
+
+	// applyKopsCluster picks the cluster up from the state store and updates it in place
+	if err := applyKopsCluster(d, deadline); err != nil {
+		return err
+	}
+
 	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
 
 	if err != nil {
@@ -624,9 +1447,84 @@ func resourceKopsUpdate(d *schema.ResourceData, meta interface{}) error {
 
 	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
 
-	clientset.UpdateCluster(&kops.Cluster{}, nil)
+	cluster, err := clientset.GetCluster(d.Id())
+	if err != nil {
+		return err
+	}
+
+	if roles, ok := rollingUpdateRoles(d); ok && !partialPhase {
+		if err := rollingUpdateCluster(clientset, cluster, roles, d.Get("rolling_update_cloud_only").(bool), deadline); err != nil {
+			return fmt.Errorf("error rolling update of cluster %q: %v", d.Id(), err)
+		}
+	}
+
+	// After the rolling update, so a failed write can't leave instances unrolled
+	if writeMappings && !partialPhase {
+		if err := writeAuthenticatorConfigMap(d, deadline); err != nil {
+			return err
+		}
+		d.Partial(false)
+	}
+
+	return resourceKopsRead(d, meta)
+}
 
-	return resourceKopsCreate(d, meta)
+// Changing these only resizes autoscaling groups, updates IAM policies or affects the provider itself, running instances are kept
+var noRollingUpdateAttributes = []string{
+	"authentication_config_map",
+	"authentication_role_mapping",
+	"authentication_user_mapping",
+	"bastion_additional_policies",
+	"deletion_protection",
+	"enable_delete_preview",
+	"hibernated",
+	"iam",
+	"lifecycle_overrides",
+	"master_additional_policies",
+	"node_additional_policies",
+	"node_max_size",
+	"node_min_size",
+	"phase",
+	"rollback_on_failure",
+	"rolling_update_cloud_only",
+	"validate_on_creation",
+}
+
+// Changing these only affects the api server, so only the masters are replaced
+var masterRollingUpdateAttributes = []string{
+	"authorization",
+}
+
+// isPartialPhase reports whether phase leaves the cluster phase to a later apply
+func isPartialPhase(phase cloudup.Phase) bool {
+	return phase == cloudup.PhaseNetwork || phase == cloudup.PhaseSecurity
+}
+
+// needsRollingUpdate reports whether anything changed that running instances need replacing for
+func needsRollingUpdate(d *schema.ResourceData) bool {
+	for k := range kopsSchema() {
+		if containsString(noRollingUpdateAttributes, k) || containsString(masterRollingUpdateAttributes, k) {
+			continue
+		}
+		if d.HasChange(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollingUpdateRoles returns the instance group roles to roll, nil meaning all of them, and whether
+// a rolling update is needed at all
+func rollingUpdateRoles(d *schema.ResourceData) ([]api.InstanceGroupRole, bool) {
+	if needsRollingUpdate(d) {
+		return nil, true
+	}
+	for _, k := range masterRollingUpdateAttributes {
+		if d.HasChange(k) {
+			return []api.InstanceGroupRole{api.InstanceGroupRoleMaster}, true
+		}
+	}
+	return nil, false
 }
 
 func resourceKopsDelete(d *schema.ResourceData, meta interface{}) error {
@@ -651,45 +1549,14 @@ func resourceKopsDelete(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	cloud, err := cloudup.BuildCloud(cluster)
-	if err != nil {
-		return err
-	}
-
-	allResources, err := ops.ListResources(cloud, name, "")
-	if err != nil {
-		return err
-	}
-
-	clusterResources := make(map[string]*resources.Resource)
-	for k, resource := range allResources {
-		if resource.Shared {
-			continue
-		}
-		clusterResources[k] = resource
+	if d.Get("deletion_protection").(bool) {
+		return fmt.Errorf("cluster %q has deletion_protection enabled, set it to false before destroying", name)
 	}
 
-	err = ops.DeleteResources(cloud, clusterResources)
-	if err != nil {
+	if err := deleteKopsCluster(clientset, cluster, time.Now().Add(d.Timeout(schema.TimeoutDelete))); err != nil {
 		return err
 	}
 
-	{
-		err := clientset.DeleteCluster(cluster)
-		if err != nil {
-			log.Printf("[DEBUG] Received error: %#v", err)
-			return err
-		}
-
-	}
-
-	conf := kubeconfig.NewKubeconfigBuilder()
-	conf.Context = name
-
-	if err = conf.DeleteKubeConfig(); err != nil {
-		log.Printf("[DEBUG] Received error: %#v", err)
-	}
-
 	d.SetId("")
 
 	return nil
@@ -0,0 +1,241 @@
+package kops
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	api "k8s.io/kops/pkg/apis/kops"
+)
+
+// expandAuthentication converts an authentication block, nil when authentication isn't set
+func expandAuthentication(l []interface{}) *api.AuthenticationSpec {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	switch m["type"].(string) {
+	case "aws":
+		return &api.AuthenticationSpec{Aws: &api.AwsAuthenticationSpec{}}
+	case "kopeio":
+		return &api.AuthenticationSpec{Kopeio: &api.KopeioAuthenticationSpec{}}
+	}
+	return nil
+}
+
+// flattenAuthentication is the inverse of expandAuthentication
+func flattenAuthentication(authentication *api.AuthenticationSpec) []interface{} {
+	if authentication == nil {
+		return nil
+	}
+	m := make(map[string]interface{})
+	if authentication.Aws != nil {
+		m["type"] = "aws"
+	} else if authentication.Kopeio != nil {
+		m["type"] = "kopeio"
+	} else {
+		return nil
+	}
+	return []interface{}{m}
+}
+
+// Where the aws-iam-authenticator addon reads its config.yaml from
+const (
+	authenticatorNamespace = "kube-system"
+	authenticatorName      = "aws-iam-authenticator"
+	authenticatorConfigKey = "config.yaml"
+)
+
+// authenticatorMapping is an entry of mapRoles or mapUsers in the aws-iam-authenticator config
+type authenticatorMapping struct {
+	RoleARN  string   `json:"roleARN,omitempty"`
+	UserARN  string   `json:"userARN,omitempty"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+type authenticatorServerConfig struct {
+	MapRoles []authenticatorMapping `json:"mapRoles,omitempty"`
+	MapUsers []authenticatorMapping `json:"mapUsers,omitempty"`
+}
+
+type authenticatorConfig struct {
+	ClusterID string                    `json:"clusterID"`
+	Server    authenticatorServerConfig `json:"server"`
+}
+
+// expandAuthenticatorMappings converts authentication_role_mapping or authentication_user_mapping entries
+func expandAuthenticatorMappings(l []interface{}, role bool) []authenticatorMapping {
+	var mappings []authenticatorMapping
+	for _, v := range l {
+		m := v.(map[string]interface{})
+		mapping := authenticatorMapping{
+			Username: m["username"].(string),
+			Groups:   expandStringList(m["groups"].([]interface{})),
+		}
+		if role {
+			mapping.RoleARN = m["arn"].(string)
+		} else {
+			mapping.UserARN = m["arn"].(string)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
+// flattenAuthenticatorMappings is the reverse of expandAuthenticatorMappings
+func flattenAuthenticatorMappings(mappings []authenticatorMapping, role bool) []interface{} {
+	l := make([]interface{}, 0, len(mappings))
+	for _, mapping := range mappings {
+		arn := mapping.UserARN
+		if role {
+			arn = mapping.RoleARN
+		}
+		groups := make([]interface{}, 0, len(mapping.Groups))
+		for _, group := range mapping.Groups {
+			groups = append(groups, group)
+		}
+		l = append(l, map[string]interface{}{
+			"arn":      arn,
+			"username": mapping.Username,
+			"groups":   groups,
+		})
+	}
+	return l
+}
+
+// flattenAuthenticatorConfigMap reads the mappings back out of an aws-iam-authenticator ConfigMap,
+// none when the ConfigMap is nil
+func flattenAuthenticatorConfigMap(configMap *v1.ConfigMap) ([]interface{}, []interface{}, error) {
+	if configMap == nil {
+		return []interface{}{}, []interface{}{}, nil
+	}
+
+	var config authenticatorConfig
+	if err := yaml.Unmarshal([]byte(configMap.Data[authenticatorConfigKey]), &config); err != nil {
+		return nil, nil, fmt.Errorf("error parsing aws-iam-authenticator config: %v", err)
+	}
+	return flattenAuthenticatorMappings(config.Server.MapRoles, true), flattenAuthenticatorMappings(config.Server.MapUsers, false), nil
+}
+
+// authenticatorConfigMap builds the ConfigMap the aws-iam-authenticator addon reads its config.yaml from.
+// Without mappings it has empty mapRoles and mapUsers, which revokes any written before
+func authenticatorConfigMap(clusterName string, roleMappings, userMappings []interface{}) (*v1.ConfigMap, error) {
+
+	config := authenticatorConfig{
+		ClusterID: clusterName,
+		Server: authenticatorServerConfig{
+			MapRoles: expandAuthenticatorMappings(roleMappings, true),
+			MapUsers: expandAuthenticatorMappings(userMappings, false),
+		},
+	}
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting aws-iam-authenticator config: %v", err)
+	}
+
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: authenticatorNamespace,
+			Name:      authenticatorName,
+			Labels: map[string]string{
+				"k8s-app": "aws-iam-authenticator",
+			},
+		},
+		Data: map[string]string{
+			authenticatorConfigKey: string(b),
+		},
+	}, nil
+}
+
+// Attributes kept at their previous values in state until the ConfigMap is written
+var authenticatorMappingAttributes = []string{
+	"authentication_config_map",
+	"authentication_role_mapping",
+	"authentication_user_mapping",
+}
+
+// authenticatorMappingsChanged reports whether the aws-iam-authenticator ConfigMap needs writing
+func authenticatorMappingsChanged(d *schema.ResourceData) bool {
+	return d.HasChange("authentication_role_mapping") || d.HasChange("authentication_user_mapping")
+}
+
+// writeAuthenticatorConfigMap writes the configured mappings to the aws-iam-authenticator ConfigMap, the
+// authenticator pods wait for it. Nothing is written unless authentication is aws
+func writeAuthenticatorConfigMap(d *schema.ResourceData, deadline time.Time) error {
+
+	authentication := expandAuthentication(d.Get("authentication").(*schema.Set).List())
+	if authentication == nil || authentication.Aws == nil {
+		return nil
+	}
+
+	configMap, err := authenticatorConfigMap(d.Id(), d.Get("authentication_role_mapping").([]interface{}), d.Get("authentication_user_mapping").([]interface{}))
+	if err != nil {
+		return err
+	}
+	return applyConfigMap(d.Id(), configMap, deadline)
+}
+
+// applyConfigMap creates or replaces configMap through the cluster's kubeconfig context, retrying
+// until the api server answers or deadline passes
+func applyConfigMap(clusterName string, configMap *v1.ConfigMap, deadline time.Time) error {
+
+	timeout, err := remainingTime(deadline, "writing ConfigMap "+configMap.ObjectMeta.Name)
+	if err != nil {
+		return err
+	}
+
+	_, k8sClient, err := kubernetesClient(clusterName)
+	if err != nil {
+		return err
+	}
+
+	configMaps := k8sClient.CoreV1().ConfigMaps(configMap.ObjectMeta.Namespace)
+	return resource.Retry(timeout, func() *resource.RetryError {
+		_, err := configMaps.Update(configMap)
+		if apierrors.IsNotFound(err) {
+			_, err = configMaps.Create(configMap)
+		}
+		if err != nil {
+			log.Printf("[DEBUG] Writing ConfigMap %s/%s: %v", configMap.ObjectMeta.Namespace, configMap.ObjectMeta.Name, err)
+			return resource.RetryableError(err)
+		}
+		return nil
+	})
+}
+
+// getAuthenticatorConfigMap reads the aws-iam-authenticator ConfigMap from the cluster, nil if there is none.
+// Every refresh calls it, so the api only gets a short time to answer
+func getAuthenticatorConfigMap(clusterName string) (*v1.ConfigMap, error) {
+
+	clientConfig, _, err := kubernetesClient(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = 30 * time.Second
+	k8sClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap, err := k8sClient.CoreV1().ConfigMaps(authenticatorNamespace).Get(authenticatorName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return configMap, err
+}
@@ -0,0 +1,64 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuthenticatorConfigMapRoundTrip(t *testing.T) {
+	cases := []struct {
+		name  string
+		roles []interface{}
+		users []interface{}
+	}{
+		{
+			name:  "none",
+			roles: []interface{}{},
+			users: []interface{}{},
+		},
+		{
+			name: "roles and users",
+			roles: []interface{}{
+				map[string]interface{}{
+					"arn":      "arn:aws:iam::000000000000:role/admin",
+					"username": "admin",
+					"groups":   []interface{}{"system:masters"},
+				},
+			},
+			users: []interface{}{
+				map[string]interface{}{
+					"arn":      "arn:aws:iam::000000000000:user/ci",
+					"username": "ci",
+					"groups":   []interface{}{},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		configMap, err := authenticatorConfigMap("test.k8s.local", c.roles, c.users)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// Without mappings the ConfigMap is still written, revoking the ones written before
+		if configMap == nil {
+			t.Fatalf("%s: got no ConfigMap", c.name)
+		}
+		roles, users, err := flattenAuthenticatorConfigMap(configMap)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(roles, c.roles) {
+			t.Errorf("%s: got roles %v, want %v", c.name, roles, c.roles)
+		}
+		if !reflect.DeepEqual(users, c.users) {
+			t.Errorf("%s: got users %v, want %v", c.name, users, c.users)
+		}
+	}
+
+	// A cluster without the ConfigMap has no mappings
+	roles, users, err := flattenAuthenticatorConfigMap(nil)
+	if err != nil || len(roles) != 0 || len(users) != 0 {
+		t.Errorf("missing ConfigMap: got %v %v %v, want no mappings", roles, users, err)
+	}
+}
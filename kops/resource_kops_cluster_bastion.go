@@ -0,0 +1,68 @@
+package kops
+
+import (
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// expandBastion applies a bastion_config block to the bastion spec and instance group
+func expandBastion(m map[string]interface{}, cluster *api.Cluster, ig *api.InstanceGroup) {
+
+	bastionSpec := cluster.Spec.Topology.Bastion
+	if v := m["public_name"].(string); v != "" {
+		bastionSpec.BastionPublicName = v
+	}
+	if v := m["idle_timeout"].(int); v != 0 {
+		bastionSpec.IdleTimeoutSeconds = fi.Int64(int64(v))
+	}
+	if l := m["load_balancer_security_groups"].([]interface{}); len(l) != 0 {
+		bastionSpec.LoadBalancer = &api.BastionLoadBalancerSpec{
+			AdditionalSecurityGroups: expandStringList(l),
+		}
+	}
+	if l := m["allowed_cidrs"].([]interface{}); len(l) != 0 {
+		cluster.Spec.SSHAccess = expandStringList(l)
+	}
+
+	if v := m["machine_type"].(string); v != "" {
+		ig.Spec.MachineType = v
+	}
+	ig.Spec.MinSize = fi.Int32(int32(m["min_size"].(int)))
+	ig.Spec.MaxSize = fi.Int32(int32(m["max_size"].(int)))
+	ig.Spec.AssociatePublicIP = fi.Bool(m["associate_public_ip"].(bool))
+	if l := m["additional_security_groups"].([]interface{}); len(l) != 0 {
+		ig.Spec.AdditionalSecurityGroups = expandStringList(l)
+	}
+}
+
+// flattenBastion is the inverse of expandBastion. allowed_cidrs and public_name are only read back when
+// set in the configured block, otherwise ssh_access and the bastion.<name> default own the values. A
+// hibernated bastion reports the sizes it is restored to
+func flattenBastion(cluster *api.Cluster, ig *api.InstanceGroup, configured map[string]interface{}) []interface{} {
+
+	minSize, maxSize, ok := hibernatedSizes(ig)
+	if !ok {
+		minSize, maxSize = fi.Int32Value(ig.Spec.MinSize), fi.Int32Value(ig.Spec.MaxSize)
+	}
+
+	m := map[string]interface{}{
+		"machine_type":               ig.Spec.MachineType,
+		"min_size":                   int(minSize),
+		"max_size":                   int(maxSize),
+		"associate_public_ip":        fi.BoolValue(ig.Spec.AssociatePublicIP),
+		"additional_security_groups": ig.Spec.AdditionalSecurityGroups,
+	}
+	if l, _ := configured["allowed_cidrs"].([]interface{}); len(l) != 0 {
+		m["allowed_cidrs"] = cluster.Spec.SSHAccess
+	}
+	if bastionSpec := cluster.Spec.Topology.Bastion; bastionSpec != nil {
+		if v, _ := configured["public_name"].(string); v != "" {
+			m["public_name"] = bastionSpec.BastionPublicName
+		}
+		m["idle_timeout"] = int(fi.Int64Value(bastionSpec.IdleTimeoutSeconds))
+		if bastionSpec.LoadBalancer != nil {
+			m["load_balancer_security_groups"] = bastionSpec.LoadBalancer.AdditionalSecurityGroups
+		}
+	}
+	return []interface{}{m}
+}
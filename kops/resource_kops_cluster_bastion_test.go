@@ -0,0 +1,69 @@
+package kops
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	api "k8s.io/kops/pkg/apis/kops"
+)
+
+func TestBastionRoundTrip(t *testing.T) {
+	hash := schema.HashResource(kopsSchema()["bastion_config"].Elem.(*schema.Resource))
+
+	// A block as Terraform reads it from the configuration, without public_name or allowed_cidrs
+	m := map[string]interface{}{
+		"additional_security_groups":    []interface{}{},
+		"allowed_cidrs":                 []interface{}{},
+		"associate_public_ip":           false,
+		"idle_timeout":                  300,
+		"load_balancer_security_groups": []interface{}{},
+		"machine_type":                  "t2.micro",
+		"max_size":                      2,
+		"min_size":                      1,
+		"public_name":                   "",
+	}
+
+	for _, hibernated := range []bool{false, true} {
+		cluster := &api.Cluster{}
+		cluster.Spec.SSHAccess = []string{"0.0.0.0/0"}
+		cluster.Spec.Topology = &api.TopologySpec{
+			Bastion: &api.BastionSpec{BastionPublicName: "bastion.test.k8s.local"},
+		}
+		ig := &api.InstanceGroup{}
+		expandBastion(m, cluster, ig)
+		if hibernated {
+			hibernateInstanceGroup(ig)
+		}
+
+		flattened := flattenBastion(cluster, ig, m)[0].(map[string]interface{})
+
+		// Read back through the schema, as Read stores it
+		d := schema.TestResourceDataRaw(t, kopsSchema(), map[string]interface{}{})
+		if err := d.Set("bastion_config", []interface{}{flattened}); err != nil {
+			t.Fatalf("hibernated %t: %v", hibernated, err)
+		}
+		stored := d.Get("bastion_config").(*schema.Set).List()[0]
+
+		if _, ok := flattened["public_name"]; ok {
+			t.Errorf("hibernated %t: public_name read back though not configured", hibernated)
+		}
+		if _, ok := flattened["allowed_cidrs"]; ok {
+			t.Errorf("hibernated %t: allowed_cidrs read back though not configured", hibernated)
+		}
+		if flattened["min_size"] != 1 || flattened["max_size"] != 2 {
+			t.Errorf("hibernated %t: got sizes %v/%v, want 1/2", hibernated, flattened["min_size"], flattened["max_size"])
+		}
+		if hash(stored) != hash(m) {
+			t.Errorf("hibernated %t: hash changed on round trip:\n%v\n%v", hibernated, m, stored)
+		}
+	}
+
+	m["public_name"] = "jump.example.com"
+	cluster := &api.Cluster{}
+	cluster.Spec.Topology = &api.TopologySpec{Bastion: &api.BastionSpec{}}
+	ig := &api.InstanceGroup{}
+	expandBastion(m, cluster, ig)
+	if got := flattenBastion(cluster, ig, m)[0].(map[string]interface{})["public_name"]; got != "jump.example.com" {
+		t.Errorf("got public_name %v, want jump.example.com", got)
+	}
+}
@@ -0,0 +1,210 @@
+package kops
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// expandEtcdClusterSpec sets the fields of an etcd_cluster block on etcdCluster and its members.
+// Unset fields are left for kops to default
+func expandEtcdClusterSpec(m map[string]interface{}, etcdCluster *api.EtcdClusterSpec) error {
+
+	if v := m["provider"].(string); v != "" {
+		etcdCluster.Provider = api.EtcdProviderType(v)
+	}
+	if v := m["version"].(string); v != "" {
+		etcdCluster.Version = v
+	}
+	if v := m["image"].(string); v != "" {
+		etcdCluster.Image = v
+	}
+	if v := m["backup_store"].(string); v != "" {
+		etcdCluster.Backups = &api.EtcdBackupSpec{
+			BackupStore: v,
+			Image:       m["backup_image"].(string),
+		}
+	}
+	if v := m["heartbeat_interval"].(string); v != "" {
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid heartbeat_interval %q: %v", v, err)
+		}
+		etcdCluster.HeartbeatInterval = &metav1.Duration{Duration: duration}
+	}
+	if v := m["leader_election_timeout"].(string); v != "" {
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid leader_election_timeout %q: %v", v, err)
+		}
+		etcdCluster.LeaderElectionTimeout = &metav1.Duration{Duration: duration}
+	}
+	etcdCluster.EnableEtcdTLS = m["enable_etcd_tls"].(bool)
+	etcdCluster.EnableTLSAuth = m["enable_tls_auth"].(bool)
+
+	for _, member := range etcdCluster.Members {
+		if v := m["volume_type"].(string); v != "" {
+			member.VolumeType = fi.String(v)
+		}
+		if v := m["volume_size"].(int); v != 0 {
+			member.VolumeSize = fi.Int32(int32(v))
+		}
+		if v := m["volume_iops"].(int); v != 0 {
+			member.VolumeIops = fi.Int32(int32(v))
+		}
+		if v := m["kms_key_id"].(string); v != "" {
+			member.KmsKeyId = fi.String(v)
+			member.EncryptedVolume = fi.Bool(true)
+		}
+	}
+	return nil
+}
+
+// flattenEtcdClusterSpec is the inverse of expandEtcdClusterSpec, volume settings are read from the first
+// member. Only the fields set in the configured block m are read back, kops fills in the others
+func flattenEtcdClusterSpec(etcdCluster *api.EtcdClusterSpec, m map[string]interface{}) map[string]interface{} {
+
+	flattened := map[string]interface{}{
+		"name":            etcdCluster.Name,
+		"enable_etcd_tls": etcdCluster.EnableEtcdTLS,
+		"enable_tls_auth": etcdCluster.EnableTLSAuth,
+	}
+	configured := func(k string) bool {
+		switch v := m[k].(type) {
+		case string:
+			return v != ""
+		case int:
+			return v != 0
+		}
+		return false
+	}
+
+	if configured("provider") {
+		flattened["provider"] = string(etcdCluster.Provider)
+	}
+	if configured("version") {
+		flattened["version"] = etcdCluster.Version
+	}
+	if configured("image") {
+		flattened["image"] = etcdCluster.Image
+	}
+	if etcdCluster.Backups != nil {
+		if configured("backup_store") {
+			flattened["backup_store"] = etcdCluster.Backups.BackupStore
+		}
+		if configured("backup_image") {
+			flattened["backup_image"] = etcdCluster.Backups.Image
+		}
+	}
+	if etcdCluster.HeartbeatInterval != nil && configured("heartbeat_interval") {
+		flattened["heartbeat_interval"] = etcdCluster.HeartbeatInterval.Duration.String()
+	}
+	if etcdCluster.LeaderElectionTimeout != nil && configured("leader_election_timeout") {
+		flattened["leader_election_timeout"] = etcdCluster.LeaderElectionTimeout.Duration.String()
+	}
+	if len(etcdCluster.Members) != 0 {
+		member := etcdCluster.Members[0]
+		if configured("volume_type") {
+			flattened["volume_type"] = fi.StringValue(member.VolumeType)
+		}
+		if configured("volume_size") {
+			flattened["volume_size"] = int(fi.Int32Value(member.VolumeSize))
+		}
+		if configured("volume_iops") {
+			flattened["volume_iops"] = int(fi.Int32Value(member.VolumeIops))
+		}
+		if configured("kms_key_id") {
+			flattened["kms_key_id"] = fi.StringValue(member.KmsKeyId)
+		}
+	}
+	return flattened
+}
+
+// etcdClusterConfigs keys etcd_cluster blocks by name. A name may only be used once
+func etcdClusterConfigs(l []interface{}) (map[string]map[string]interface{}, error) {
+	configs := make(map[string]map[string]interface{})
+	for _, v := range l {
+		m := v.(map[string]interface{})
+		name := m["name"].(string)
+		if _, ok := configs[name]; ok {
+			return nil, fmt.Errorf("etcd_cluster %q is configured more than once", name)
+		}
+		configs[name] = m
+	}
+	return configs, nil
+}
+
+// flattenEtcdSettings returns etcd_version and encrypt_etcd_storage from the etcd clusters. Clusters
+// whose block overrides the version or sets a KMS key, which implies encryption, don't count; ok is
+// false for a setting no cluster is left to read it from
+func flattenEtcdSettings(etcdClusters []*api.EtcdClusterSpec, configs map[string]map[string]interface{}) (version string, versionOK bool, encrypted bool, encryptedOK bool) {
+	for _, etcdCluster := range etcdClusters {
+		m := configs[etcdCluster.Name]
+		if !versionOK && (m == nil || m["version"].(string) == "") {
+			version, versionOK = etcdCluster.Version, true
+		}
+		if !encryptedOK && len(etcdCluster.Members) != 0 && (m == nil || m["kms_key_id"].(string) == "") {
+			encrypted, encryptedOK = fi.BoolValue(etcdCluster.Members[0].EncryptedVolume), true
+		}
+	}
+	return
+}
+
+// validateDuration checks that a string attribute parses as a Go duration e.g. 250ms
+func validateDuration(v interface{}, k string) (ws []string, errors []error) {
+	if s := v.(string); s != "" {
+		if _, err := time.ParseDuration(s); err != nil {
+			errors = append(errors, fmt.Errorf("%q: invalid duration %q: %v", k, s, err))
+		}
+	}
+	return
+}
+
+// suppressEquivalentDuration ignores differences in how the same duration is written, e.g. 1200ms and 1.2s
+func suppressEquivalentDuration(k, old, new string, d *schema.ResourceData) bool {
+	return normalizeDuration(old) == normalizeDuration(new)
+}
+
+// normalizeDuration returns s in Go's duration format, or as is if it doesn't parse
+func normalizeDuration(s string) string {
+	if s == "" {
+		return s
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return s
+	}
+	return duration.String()
+}
+
+// etcdClusterHash hashes an etcd_cluster block with its durations normalized, so reading back
+// 1200ms as 1.2s doesn't replace the block
+func etcdClusterHash(v interface{}) int {
+	m := v.(map[string]interface{})
+
+	var buf bytes.Buffer
+	// Unset attributes may be missing rather than zero, so both hash the same
+	for _, k := range []string{"name", "provider", "version", "image", "backup_store", "backup_image", "volume_type", "kms_key_id"} {
+		s, _ := m[k].(string)
+		buf.WriteString(fmt.Sprintf("%s-", s))
+	}
+	for _, k := range []string{"volume_size", "volume_iops"} {
+		i, _ := m[k].(int)
+		buf.WriteString(fmt.Sprintf("%d-", i))
+	}
+	for _, k := range []string{"heartbeat_interval", "leader_election_timeout"} {
+		s, _ := m[k].(string)
+		buf.WriteString(fmt.Sprintf("%s-", normalizeDuration(s)))
+	}
+	for _, k := range []string{"enable_etcd_tls", "enable_tls_auth"} {
+		b, _ := m[k].(bool)
+		buf.WriteString(fmt.Sprintf("%t-", b))
+	}
+	return hashcode.String(buf.String())
+}
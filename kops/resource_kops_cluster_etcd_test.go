@@ -0,0 +1,188 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+func TestEtcdClusterSpecRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		m    map[string]interface{}
+	}{
+		{
+			name: "all fields",
+			m: map[string]interface{}{
+				"name":                    "main",
+				"provider":                "Manager",
+				"version":                 "3.2.24",
+				"image":                   "",
+				"backup_store":            "s3://bucket/backups/etcd/main",
+				"backup_image":            "",
+				"volume_type":             "gp2",
+				"volume_size":             20,
+				"volume_iops":             0,
+				"kms_key_id":              "arn:aws:kms:us-east-1:123456789012:key/abc",
+				"heartbeat_interval":      "250ms",
+				"leader_election_timeout": "1200ms",
+				"enable_etcd_tls":         true,
+				"enable_tls_auth":         false,
+			},
+		},
+		{
+			// As in example/main.tf, kops and etcd_version fill in the rest
+			name: "without version",
+			m: map[string]interface{}{
+				"name":                    "main",
+				"provider":                "Manager",
+				"version":                 "",
+				"image":                   "",
+				"backup_store":            "s3://bucket/backups/etcd/main",
+				"backup_image":            "",
+				"volume_type":             "gp2",
+				"volume_size":             20,
+				"volume_iops":             0,
+				"kms_key_id":              "",
+				"heartbeat_interval":      "",
+				"leader_election_timeout": "",
+				"enable_etcd_tls":         false,
+				"enable_tls_auth":         false,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		etcdCluster := &api.EtcdClusterSpec{
+			Name:    "main",
+			Version: "3.2.18",
+			Image:   "k8s.gcr.io/etcd:3.2.18",
+			Members: []*api.EtcdMemberSpec{{Name: "a"}, {Name: "b"}},
+		}
+		if err := expandEtcdClusterSpec(c.m, etcdCluster); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.name, err)
+		}
+		for _, member := range etcdCluster.Members {
+			if fi.Int32Value(member.VolumeSize) != 20 {
+				t.Errorf("%s: member %s: volume settings not applied: %+v", c.name, member.Name, member)
+			}
+			if fi.BoolValue(member.EncryptedVolume) != (c.m["kms_key_id"] != "") {
+				t.Errorf("%s: member %s: a KMS key must imply encryption", c.name, member.Name)
+			}
+		}
+
+		flattened := flattenEtcdClusterSpec(etcdCluster, c.m)
+		if etcdClusterHash(flattened) != etcdClusterHash(c.m) {
+			t.Errorf("%s: hash changed on round trip:\n%v\n%v", c.name, c.m, flattened)
+		}
+		for k, v := range c.m {
+			got, ok := flattened[k]
+			if !ok {
+				if !reflect.DeepEqual(v, reflect.Zero(reflect.TypeOf(v)).Interface()) {
+					t.Errorf("%s: %s: not read back, want %v", c.name, k, v)
+				}
+				continue
+			}
+			if k == "heartbeat_interval" || k == "leader_election_timeout" {
+				if !suppressEquivalentDuration(k, v.(string), got.(string), nil) {
+					t.Errorf("%s: %s: %v and %v should be equivalent", c.name, k, v, got)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, v) {
+				t.Errorf("%s: %s: got %v, want %v", c.name, k, got, v)
+			}
+		}
+	}
+
+	m := map[string]interface{}{"name": "main", "leader_election_timeout": "1200ms"}
+	if etcdClusterHash(m) == etcdClusterHash(map[string]interface{}{"name": "main", "leader_election_timeout": "1s"}) {
+		t.Errorf("different durations must hash differently")
+	}
+	if etcdClusterHash(m) != etcdClusterHash(map[string]interface{}{"name": "main", "leader_election_timeout": "1.2s"}) {
+		t.Errorf("equivalent durations must hash the same")
+	}
+}
+
+func TestEtcdClusterConfigs(t *testing.T) {
+	main := map[string]interface{}{"name": "main"}
+	events := map[string]interface{}{"name": "events"}
+
+	configs, err := etcdClusterConfigs([]interface{}{main, events})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Errorf("got %v, want main and events", configs)
+	}
+
+	if _, err := etcdClusterConfigs([]interface{}{main, map[string]interface{}{"name": "main", "version": "3.3.10"}}); err == nil {
+		t.Errorf("expected an error for main configured twice")
+	}
+}
+
+func TestFlattenEtcdSettings(t *testing.T) {
+	etcdClusters := []*api.EtcdClusterSpec{
+		{Name: "main", Version: "3.3.10", Members: []*api.EtcdMemberSpec{{EncryptedVolume: fi.Bool(true)}}},
+		{Name: "events", Version: "3.2.24", Members: []*api.EtcdMemberSpec{{}}},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		configs     map[string]map[string]interface{}
+		version     string
+		versionOK   bool
+		encrypted   bool
+		encryptedOK bool
+	}{
+		{
+			name:        "no blocks",
+			version:     "3.3.10",
+			versionOK:   true,
+			encrypted:   true,
+			encryptedOK: true,
+		},
+		{
+			name: "main overrides version and sets a key",
+			configs: map[string]map[string]interface{}{
+				"main": {"version": "3.3.10", "kms_key_id": "key"},
+			},
+			version:     "3.2.24",
+			versionOK:   true,
+			encrypted:   false,
+			encryptedOK: true,
+		},
+		{
+			name: "both override",
+			configs: map[string]map[string]interface{}{
+				"main":   {"version": "3.3.10", "kms_key_id": "key"},
+				"events": {"version": "3.2.24", "kms_key_id": "key"},
+			},
+		},
+	} {
+		version, versionOK, encrypted, encryptedOK := flattenEtcdSettings(etcdClusters, tc.configs)
+		if version != tc.version || versionOK != tc.versionOK || encrypted != tc.encrypted || encryptedOK != tc.encryptedOK {
+			t.Errorf("%s: got %q %t %t %t, want %q %t %t %t", tc.name, version, versionOK, encrypted, encryptedOK, tc.version, tc.versionOK, tc.encrypted, tc.encryptedOK)
+		}
+	}
+}
+
+func TestValidateDuration(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		valid bool
+	}{
+		{"", true},
+		{"250ms", true},
+		{"1.2s", true},
+		{"1200", false},
+		{"soon", false},
+	} {
+		_, errors := validateDuration(tc.value, "heartbeat_interval")
+		if (len(errors) == 0) != tc.valid {
+			t.Errorf("%q: got errors %v, want valid %t", tc.value, errors, tc.valid)
+		}
+	}
+}
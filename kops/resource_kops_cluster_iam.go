@@ -0,0 +1,77 @@
+package kops
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// instanceGroupRoles are the roles with their own <role>_ attributes, as used by kops for additional policies
+var instanceGroupRoles = []string{"master", "node", "bastion"}
+
+// expandIAM converts an iam block, defaulting to the container registry allowed and the strict policies
+func expandIAM(l []interface{}) *api.IAMSpec {
+	iam := &api.IAMSpec{
+		AllowContainerRegistry: true,
+		Legacy:                 false,
+	}
+	if len(l) != 0 && l[0] != nil {
+		m := l[0].(map[string]interface{})
+		iam.AllowContainerRegistry = m["allow_container_registry"].(bool)
+		iam.Legacy = m["legacy"].(bool)
+	}
+	return iam
+}
+
+// flattenIAM is the inverse of expandIAM
+func flattenIAM(iam *api.IAMSpec) []interface{} {
+	if iam == nil {
+		return nil
+	}
+	return []interface{}{
+		map[string]interface{}{
+			"allow_container_registry": iam.AllowContainerRegistry,
+			"legacy":                   iam.Legacy,
+		},
+	}
+}
+
+// expandAdditionalPolicies collects the <role>_additional_policies attributes, keyed by role
+func expandAdditionalPolicies(d *schema.ResourceData) map[string]string {
+	policies := make(map[string]string)
+	for _, role := range instanceGroupRoles {
+		if v, ok := d.GetOk(role + "_additional_policies"); ok {
+			policies[role] = v.(string)
+		}
+	}
+	return policies
+}
+
+// flattenAdditionalPolicies is the inverse of expandAdditionalPolicies
+func flattenAdditionalPolicies(d *schema.ResourceData, policies *map[string]string) {
+	for _, role := range instanceGroupRoles {
+		policy := ""
+		if policies != nil {
+			policy = (*policies)[role]
+		}
+		d.Set(role+"_additional_policies", policy)
+	}
+}
+
+// expandInstanceProfile sets <role>_iam_instance_profile on an instance group spec
+func expandInstanceProfile(d *schema.ResourceData, role string, spec *api.InstanceGroupSpec) {
+	if v, ok := d.GetOk(role + "_iam_instance_profile"); ok {
+		spec.IAM = &api.IAMProfileSpec{
+			Profile: fi.String(v.(string)),
+		}
+	}
+}
+
+// flattenInstanceProfile is the inverse of expandInstanceProfile
+func flattenInstanceProfile(d *schema.ResourceData, role string, spec *api.InstanceGroupSpec) {
+	profile := ""
+	if spec.IAM != nil {
+		profile = fi.StringValue(spec.IAM.Profile)
+	}
+	d.Set(role+"_iam_instance_profile", profile)
+}
@@ -0,0 +1,181 @@
+package kops
+
+import (
+	"strconv"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// flattenImage reads back an instance group's image into <role>_image, or into image when the
+// role falls back to it
+func flattenImage(d *schema.ResourceData, role string, image string) {
+	if _, ok := d.GetOk(role + "_image"); ok {
+		d.Set(role+"_image", image)
+	} else {
+		d.Set("image", image)
+	}
+}
+
+// expandRootVolume sets the <role>_volume_type, _iops and _optimization attributes on an instance group spec.
+// The bastion group has no size attribute of its own, so bastion_volume_size is handled here too.
+// Root volume encryption with a customer KMS key is not supported: the kops 1.11 InstanceGroupSpec has no
+// field for it, later kops releases add rootVolumeEncryption. Until then use an AMI with encrypted snapshots
+func expandRootVolume(d *schema.ResourceData, role string, spec *api.InstanceGroupSpec) {
+	if v, ok := d.GetOk(role + "_volume_size"); ok && spec.RootVolumeSize == nil {
+		spec.RootVolumeSize = fi.Int32(int32(v.(int)))
+	}
+	if v, ok := d.GetOk(role + "_volume_type"); ok {
+		spec.RootVolumeType = fi.String(v.(string))
+	}
+	if v, ok := d.GetOk(role + "_volume_iops"); ok {
+		spec.RootVolumeIops = fi.Int32(int32(v.(int)))
+	}
+	if v, ok := d.GetOk(role + "_volume_optimization"); ok {
+		spec.RootVolumeOptimization = fi.Bool(v.(bool))
+	}
+}
+
+// flattenRootVolume is the inverse of expandRootVolume
+func flattenRootVolume(d *schema.ResourceData, role string, spec *api.InstanceGroupSpec) {
+	d.Set(role+"_volume_type", fi.StringValue(spec.RootVolumeType))
+	d.Set(role+"_volume_iops", int(fi.Int32Value(spec.RootVolumeIops)))
+	d.Set(role+"_volume_optimization", fi.BoolValue(spec.RootVolumeOptimization))
+}
+
+// Label set on every instance group the provider saves. Only labelled groups are deleted when they drop out
+// of the configuration, groups added with kops create ig are left alone
+const (
+	managedByLabel = "kops.terraform.io/managed-by"
+	managedByValue = "terraform"
+)
+
+// removedInstanceGroups returns the stored instance groups the provider created that are not in instanceGroups
+func removedInstanceGroups(stored []api.InstanceGroup, instanceGroups []*api.InstanceGroup) []*api.InstanceGroup {
+
+	keep := make(map[string]bool)
+	for _, ig := range instanceGroups {
+		keep[ig.ObjectMeta.Name] = true
+	}
+
+	var removed []*api.InstanceGroup
+	for i := range stored {
+		ig := &stored[i]
+		if keep[ig.ObjectMeta.Name] || ig.ObjectMeta.Labels[managedByLabel] != managedByValue {
+			continue
+		}
+		removed = append(removed, ig)
+	}
+	return removed
+}
+
+// isProviderNodeGroup reports whether ig is the nodes group or a nodes-<zone> group of node_group_per_zone,
+// rather than one added with kops create ig. Groups saved before the managed-by label lack it, so the
+// name and zone are checked instead
+func isProviderNodeGroup(ig *api.InstanceGroup) bool {
+	if ig.Spec.Role != api.InstanceGroupRoleNode {
+		return false
+	}
+	if ig.ObjectMeta.Name == "nodes" {
+		return true
+	}
+	zones := instanceGroupZones(ig)
+	return len(zones) == 1 && ig.ObjectMeta.Name == "nodes-"+zones[0]
+}
+
+// masterGroup is a master instance group to create, of size instances in zone
+type masterGroup struct {
+	name string
+	zone string
+	size int32
+}
+
+// masterGroups lays out perZone masters in each of zones, one instance group per master so each gets its
+// own etcd member: master-<zone>, or master-<zone>-a, -b ... with several per zone. Clusters created
+// before that keep their legacy layout of a single master-<zone> group of perZone instances, as renaming
+// the groups would replace the masters and their etcd members
+func masterGroups(zones []string, perZone int, legacy bool) []masterGroup {
+
+	var groups []masterGroup
+	if legacy || perZone == 1 {
+		for _, zone := range zones {
+			groups = append(groups, masterGroup{name: "master-" + zone, zone: zone, size: int32(perZone)})
+		}
+		return groups
+	}
+
+	for i := 0; i < perZone; i++ {
+		for _, zone := range zones {
+			name := "master-" + zone + "-" + string(rune('a'+i))
+			groups = append(groups, masterGroup{name: name, zone: zone, size: 1})
+		}
+	}
+	return groups
+}
+
+// instanceGroupSize returns the maximum size of ig, or the size it is restored to if hibernated
+func instanceGroupSize(ig *api.InstanceGroup) int32 {
+	if _, maxSize, ok := hibernatedSizes(ig); ok {
+		return maxSize
+	}
+	return fi.Int32Value(ig.Spec.MaxSize)
+}
+
+// legacyMasterLayout reports whether stored has a master group of several instances, the layout
+// master_per_zone used before each master got its own group
+func legacyMasterLayout(stored []api.InstanceGroup) bool {
+	for i := range stored {
+		if stored[i].Spec.Role == api.InstanceGroupRoleMaster && instanceGroupSize(&stored[i]) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// storedMasterCount returns the number of masters of the stored instance groups
+func storedMasterCount(stored []api.InstanceGroup) int {
+	count := 0
+	for i := range stored {
+		if stored[i].Spec.Role == api.InstanceGroupRoleMaster {
+			count += int(instanceGroupSize(&stored[i]))
+		}
+	}
+	return count
+}
+
+// Annotations recording the sizes of a hibernated instance group, so they can be restored
+const (
+	hibernatedMinSizeAnnotation = "kops.terraform.io/hibernated-min-size"
+	hibernatedMaxSizeAnnotation = "kops.terraform.io/hibernated-max-size"
+)
+
+// hibernateInstanceGroup records the configured sizes of ig in its annotations and scales it to zero.
+// Recording the configured rather than the stored sizes lets sizes change while hibernated
+func hibernateInstanceGroup(ig *api.InstanceGroup) {
+	if ig.ObjectMeta.Annotations == nil {
+		ig.ObjectMeta.Annotations = make(map[string]string)
+	}
+	ig.ObjectMeta.Annotations[hibernatedMinSizeAnnotation] = strconv.Itoa(int(fi.Int32Value(ig.Spec.MinSize)))
+	ig.ObjectMeta.Annotations[hibernatedMaxSizeAnnotation] = strconv.Itoa(int(fi.Int32Value(ig.Spec.MaxSize)))
+	ig.Spec.MinSize = fi.Int32(0)
+	ig.Spec.MaxSize = fi.Int32(0)
+}
+
+// hibernatedSizes returns the sizes recorded by hibernateInstanceGroup, ok is false if ig is not hibernated
+func hibernatedSizes(ig *api.InstanceGroup) (minSize int32, maxSize int32, ok bool) {
+	minValue, hasMin := ig.ObjectMeta.Annotations[hibernatedMinSizeAnnotation]
+	maxValue, hasMax := ig.ObjectMeta.Annotations[hibernatedMaxSizeAnnotation]
+	if !hasMin || !hasMax {
+		return 0, 0, false
+	}
+	min, err := strconv.Atoi(minValue)
+	if err != nil {
+		return 0, 0, false
+	}
+	max, err := strconv.Atoi(maxValue)
+	if err != nil {
+		return 0, 0, false
+	}
+	return int32(min), int32(max), true
+}
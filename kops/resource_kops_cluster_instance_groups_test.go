@@ -0,0 +1,255 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+func TestRemovedInstanceGroups(t *testing.T) {
+	group := func(name string, managed bool) api.InstanceGroup {
+		ig := api.InstanceGroup{}
+		ig.ObjectMeta.Name = name
+		ig.ObjectMeta.Labels = map[string]string{api.LabelClusterName: "test.example.com"}
+		if managed {
+			ig.ObjectMeta.Labels[managedByLabel] = managedByValue
+		}
+		return ig
+	}
+	wanted := func(names ...string) []*api.InstanceGroup {
+		var l []*api.InstanceGroup
+		for _, name := range names {
+			ig := group(name, true)
+			l = append(l, &ig)
+		}
+		return l
+	}
+
+	tests := []struct {
+		name   string
+		stored []api.InstanceGroup
+		keep   []*api.InstanceGroup
+		want   []string
+	}{
+		{
+			name:   "nothing removed",
+			stored: []api.InstanceGroup{group("nodes", true), group("master-us-east-1a", true)},
+			keep:   wanted("nodes", "master-us-east-1a"),
+		},
+		{
+			name:   "managed group dropped from the configuration",
+			stored: []api.InstanceGroup{group("nodes", true), group("bastions", true)},
+			keep:   wanted("nodes"),
+			want:   []string{"bastions"},
+		},
+		{
+			name:   "group added with the kops cli is kept",
+			stored: []api.InstanceGroup{group("nodes", true), group("gpu-nodes", false)},
+			keep:   wanted("nodes"),
+		},
+		{
+			name:   "group created before the label was introduced is kept",
+			stored: []api.InstanceGroup{group("nodes", false), group("bastions", false)},
+			keep:   wanted("nodes"),
+		},
+	}
+
+	for _, tc := range tests {
+		var got []string
+		for _, ig := range removedInstanceGroups(tc.stored, tc.keep) {
+			got = append(got, ig.ObjectMeta.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMasterGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		zones   []string
+		perZone int
+		legacy  bool
+		want    []masterGroup
+	}{
+		{
+			name:    "one per zone",
+			zones:   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+			perZone: 1,
+			want: []masterGroup{
+				{"master-us-east-1a", "us-east-1a", 1},
+				{"master-us-east-1b", "us-east-1b", 1},
+				{"master-us-east-1c", "us-east-1c", 1},
+			},
+		},
+		{
+			name:    "several per zone",
+			zones:   []string{"us-east-1a"},
+			perZone: 3,
+			want: []masterGroup{
+				{"master-us-east-1a-a", "us-east-1a", 1},
+				{"master-us-east-1a-b", "us-east-1a", 1},
+				{"master-us-east-1a-c", "us-east-1a", 1},
+			},
+		},
+		{
+			name:    "legacy layout keeps one group per zone",
+			zones:   []string{"us-east-1a"},
+			perZone: 3,
+			legacy:  true,
+			want: []masterGroup{
+				{"master-us-east-1a", "us-east-1a", 3},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		got := masterGroups(tc.zones, tc.perZone, tc.legacy)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLegacyMasterLayout(t *testing.T) {
+	group := func(name string, role api.InstanceGroupRole, size int32, hibernated bool) api.InstanceGroup {
+		ig := api.InstanceGroup{}
+		ig.ObjectMeta.Name = name
+		ig.Spec.Role = role
+		ig.Spec.MinSize = fi.Int32(size)
+		ig.Spec.MaxSize = fi.Int32(size)
+		if hibernated {
+			hibernateInstanceGroup(&ig)
+		}
+		return ig
+	}
+
+	tests := []struct {
+		name    string
+		stored  []api.InstanceGroup
+		legacy  bool
+		masters int
+	}{
+		{
+			name: "new cluster",
+		},
+		{
+			name: "one master per group",
+			stored: []api.InstanceGroup{
+				group("master-us-east-1a-a", api.InstanceGroupRoleMaster, 1, false),
+				group("master-us-east-1a-b", api.InstanceGroupRoleMaster, 1, false),
+				group("master-us-east-1a-c", api.InstanceGroupRoleMaster, 1, false),
+				group("nodes", api.InstanceGroupRoleNode, 5, false),
+			},
+			masters: 3,
+		},
+		{
+			name: "several masters in one group",
+			stored: []api.InstanceGroup{
+				group("master-us-east-1a", api.InstanceGroupRoleMaster, 3, false),
+				group("nodes", api.InstanceGroupRoleNode, 5, false),
+			},
+			legacy:  true,
+			masters: 3,
+		},
+		{
+			name: "hibernated legacy group",
+			stored: []api.InstanceGroup{
+				group("master-us-east-1a", api.InstanceGroupRoleMaster, 3, true),
+			},
+			legacy:  true,
+			masters: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		if got := legacyMasterLayout(tc.stored); got != tc.legacy {
+			t.Errorf("%s: got legacy %t, want %t", tc.name, got, tc.legacy)
+		}
+		if got := storedMasterCount(tc.stored); got != tc.masters {
+			t.Errorf("%s: got %d masters, want %d", tc.name, got, tc.masters)
+		}
+	}
+}
+
+func TestHibernation(t *testing.T) {
+	group := func(minSize, maxSize int32) *api.InstanceGroup {
+		ig := &api.InstanceGroup{}
+		ig.ObjectMeta.Name = "nodes"
+		ig.Spec.Role = api.InstanceGroupRoleNode
+		ig.Spec.MinSize = fi.Int32(minSize)
+		ig.Spec.MaxSize = fi.Int32(maxSize)
+		return ig
+	}
+
+	tests := []struct {
+		name      string
+		sizes     [2]int32
+		wantSaved [2]int32
+	}{
+		{
+			name:      "hibernating records the configured sizes",
+			sizes:     [2]int32{2, 4},
+			wantSaved: [2]int32{2, 4},
+		},
+		{
+			// Another apply while hibernated, with node_min_size and node_max_size changed
+			name:      "sizes changed while hibernated are recorded",
+			sizes:     [2]int32{5, 8},
+			wantSaved: [2]int32{5, 8},
+		},
+	}
+
+	for _, tc := range tests {
+		ig := group(tc.sizes[0], tc.sizes[1])
+		hibernateInstanceGroup(ig)
+
+		if got := [2]int32{fi.Int32Value(ig.Spec.MinSize), fi.Int32Value(ig.Spec.MaxSize)}; got != [2]int32{0, 0} {
+			t.Errorf("%s: got sizes %v, want 0/0", tc.name, got)
+		}
+		minSize, maxSize, ok := hibernatedSizes(ig)
+		if !ok {
+			t.Fatalf("%s: sizes not recorded", tc.name)
+		}
+		if [2]int32{minSize, maxSize} != tc.wantSaved {
+			t.Errorf("%s: got recorded sizes %v, want %v", tc.name, [2]int32{minSize, maxSize}, tc.wantSaved)
+		}
+		// Read reports the recorded sizes, so the changed ones converge in one apply
+		if got := instanceGroupSize(ig); got != tc.wantSaved[1] {
+			t.Errorf("%s: got size %d, want %d", tc.name, got, tc.wantSaved[1])
+		}
+	}
+
+	if _, _, ok := hibernatedSizes(group(2, 4)); ok {
+		t.Errorf("a group that isn't hibernated must not report recorded sizes")
+	}
+}
+
+func TestIsProviderNodeGroup(t *testing.T) {
+	group := func(name string, role api.InstanceGroupRole, subnets ...string) *api.InstanceGroup {
+		ig := &api.InstanceGroup{}
+		ig.ObjectMeta.Name = name
+		ig.Spec.Role = role
+		ig.Spec.Subnets = subnets
+		return ig
+	}
+
+	for _, tc := range []struct {
+		ig   *api.InstanceGroup
+		want bool
+	}{
+		{group("nodes", api.InstanceGroupRoleNode, "us-east-1a", "us-east-1c"), true},
+		{group("nodes-us-east-1a", api.InstanceGroupRoleNode, "us-east-1a"), true},
+		{group("nodes-spot", api.InstanceGroupRoleNode, "us-east-1a"), false},
+		{group("nodes-us-east-1a", api.InstanceGroupRoleNode, "us-east-1a", "us-east-1c"), false},
+		{group("gpu", api.InstanceGroupRoleNode, "us-east-1a"), false},
+		{group("master-us-east-1a", api.InstanceGroupRoleMaster, "us-east-1a"), false},
+	} {
+		if got := isProviderNodeGroup(tc.ig); got != tc.want {
+			t.Errorf("%s %v: got %t, want %t", tc.ig.ObjectMeta.Name, tc.ig.Spec.Subnets, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package kops
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	api "k8s.io/kops/pkg/apis/kops"
+	apivalidation "k8s.io/kops/pkg/apis/kops/validation"
+	"k8s.io/kops/pkg/client/simple/vfsclientset"
+	"k8s.io/kops/pkg/diff"
+	"k8s.io/kops/pkg/kopscodecs"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kops/util/pkg/vfs"
+)
+
+func resourceKopsClusterManifest() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKopsClusterManifestCreate,
+		Read:   resourceKopsClusterManifestRead,
+		Update: resourceKopsClusterManifestUpdate,
+		Delete: resourceKopsClusterManifestDelete,
+		Schema: kopsClusterManifestSchema(),
+		// As for kops_cluster these bound when each step may start, not how long it runs
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
+	}
+}
+
+func resourceKopsClusterManifestCreate(d *schema.ResourceData, meta interface{}) error {
+
+	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
+	if err != nil {
+		return fmt.Errorf("error parsing registry path %q: %v", d.Get("state_store").(string), err)
+	}
+	allowList := true
+
+	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
+
+	cluster, instanceGroups, err := parseClusterManifest(d.Get("manifest").(string), registryBase)
+	if err != nil {
+		return err
+	}
+
+	var pubKey []byte
+	if v, ok := d.GetOk("ssh_public_key"); ok {
+		f := utils.ExpandPath(v.(string))
+		pubKey, err = ioutil.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("error reading SSH key file %q: %v", f, err)
+		}
+	}
+
+	// Same as kops_cluster, resume a create that failed after writing to the state store
+	existing, err := getExistingCluster(clientset, cluster.ObjectMeta.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		log.Printf("[INFO] Kops Cluster %s already exists in state store, resuming create", cluster.ObjectMeta.Name)
+		cluster.ObjectMeta.CreationTimestamp = existing.ObjectMeta.CreationTimestamp
+	}
+
+	err = saveAndApplyCluster(clientset, cluster, instanceGroups, pubKey, existing != nil, nil, "", time.Now().Add(d.Timeout(schema.TimeoutCreate)))
+	if err != nil {
+		return err
+	}
+
+	if err := writeKubeconfig(clientset, cluster); err != nil {
+		return err
+	}
+	d.SetId(cluster.ObjectMeta.Name)
+
+	return resourceKopsClusterManifestRead(d, meta)
+}
+
+func resourceKopsClusterManifestRead(d *schema.ResourceData, meta interface{}) error {
+
+	name := d.Id()
+
+	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
+	if err != nil {
+		return fmt.Errorf("error parsing registry path %q: %v", d.Get("state_store").(string), err)
+	}
+	allowList := true
+
+	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
+
+	log.Printf("[INFO] Reading Kops Cluster %s", name)
+	cluster, err := getExistingCluster(clientset, name)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		log.Printf("[WARN] Kops Cluster %s not found in state store, removing from state", name)
+		d.SetId("")
+		return nil
+	}
+
+	list, err := clientset.InstanceGroupsFor(cluster).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot get InstanceGroups for %q: %v", name, err)
+	}
+
+	var instanceGroups []*api.InstanceGroup
+	for i := range list.Items {
+		instanceGroups = append(instanceGroups, &list.Items[i])
+	}
+
+	stored, err := formatClusterManifest(cluster, instanceGroups)
+	if err != nil {
+		return err
+	}
+
+	d.Set("name", cluster.ObjectMeta.Name)
+
+	// Only replace the manifest when it no longer matches the state store, so
+	// formatting differences in the configured YAML don't show up as a diff
+	desiredCluster, desiredInstanceGroups, err := parseClusterManifest(d.Get("manifest").(string), registryBase)
+	if err != nil {
+		return fmt.Errorf("error parsing manifest of cluster %q: %v", name, err)
+	}
+	desired, err := formatClusterManifest(desiredCluster, desiredInstanceGroups)
+	if err != nil {
+		return err
+	}
+
+	if desired != stored {
+		log.Printf("[INFO] Kops Cluster %s differs from its manifest", name)
+		d.Set("manifest", stored)
+		d.Set("drift", diff.FormatDiff(desired, stored))
+	} else {
+		d.Set("drift", "")
+	}
+
+	return nil
+}
+
+func resourceKopsClusterManifestUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
+	if err != nil {
+		return fmt.Errorf("error parsing registry path %q: %v", d.Get("state_store").(string), err)
+	}
+	allowList := true
+
+	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
+
+	cluster, instanceGroups, err := parseClusterManifest(d.Get("manifest").(string), registryBase)
+	if err != nil {
+		return err
+	}
+	if cluster.ObjectMeta.Name != d.Id() {
+		return fmt.Errorf("cluster name cannot be changed from %q to %q", d.Id(), cluster.ObjectMeta.Name)
+	}
+
+	existing, err := clientset.GetCluster(d.Id())
+	if err != nil {
+		return err
+	}
+	cluster.ObjectMeta.CreationTimestamp = existing.ObjectMeta.CreationTimestamp
+
+	// The apply and rolling update share the update timeout
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutUpdate))
+	err = saveAndApplyCluster(clientset, cluster, instanceGroups, nil, true, nil, "", deadline)
+	if err != nil {
+		return err
+	}
+
+	if err := rollingUpdateCluster(clientset, cluster, nil, d.Get("rolling_update_cloud_only").(bool), deadline); err != nil {
+		return fmt.Errorf("error rolling update of cluster %q: %v", d.Id(), err)
+	}
+
+	return resourceKopsClusterManifestRead(d, meta)
+}
+
+func resourceKopsClusterManifestDelete(d *schema.ResourceData, meta interface{}) error {
+
+	name := d.Id()
+
+	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
+	if err != nil {
+		return fmt.Errorf("error parsing registry path %q: %v", d.Get("state_store").(string), err)
+	}
+	allowList := true
+
+	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
+
+	log.Printf("[INFO] Reading Kops Cluster %s", name)
+
+	cluster, err := clientset.GetCluster(name)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteKopsCluster(clientset, cluster, time.Now().Add(d.Timeout(schema.TimeoutDelete))); err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	return nil
+}
+
+// parseClusterManifest decodes a single Cluster and any number of InstanceGroup documents, the same as kops create -f
+func parseClusterManifest(manifest string, registryBase vfs.Path) (*api.Cluster, []*api.InstanceGroup, error) {
+
+	var cluster *api.Cluster
+	var instanceGroups []*api.InstanceGroup
+
+	codec := kopscodecs.Codecs.UniversalDecoder(api.SchemeGroupVersion)
+
+	sections, err := splitManifest(manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, section := range sections {
+		o, gvk, err := codec.Decode(section, nil, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing manifest: %v", err)
+		}
+
+		switch v := o.(type) {
+		case *api.Cluster:
+			if cluster != nil {
+				return nil, nil, fmt.Errorf("manifest must contain a single Cluster")
+			}
+			cluster = v
+		case *api.InstanceGroup:
+			instanceGroups = append(instanceGroups, v)
+		default:
+			return nil, nil, fmt.Errorf("unhandled kind %v in manifest", gvk)
+		}
+	}
+
+	if cluster == nil {
+		return nil, nil, fmt.Errorf("manifest must contain a Cluster")
+	}
+	if len(instanceGroups) == 0 {
+		return nil, nil, fmt.Errorf("manifest must contain at least one InstanceGroup")
+	}
+
+	if cluster.Spec.ConfigBase == "" {
+		cluster.Spec.ConfigBase = registryBase.Join(cluster.ObjectMeta.Name).Path()
+	}
+
+	if err := apivalidation.ValidateCluster(cluster, false); err != nil {
+		return nil, nil, fmt.Errorf("invalid Cluster %q: %v", cluster.ObjectMeta.Name, err)
+	}
+
+	for _, ig := range instanceGroups {
+		if err := apivalidation.ValidateInstanceGroup(ig); err != nil {
+			return nil, nil, err
+		}
+		// The state store labels every instance group with its cluster
+		if ig.ObjectMeta.Labels == nil {
+			ig.ObjectMeta.Labels = make(map[string]string)
+		}
+		ig.ObjectMeta.Labels[api.LabelClusterName] = cluster.ObjectMeta.Name
+	}
+
+	return cluster, instanceGroups, nil
+}
+
+// splitManifest returns the non empty YAML documents of manifest. Separators may have trailing
+// spaces or CRLF line endings, and a leading separator is allowed, the same as kubectl apply -f
+func splitManifest(manifest string) ([][]byte, error) {
+
+	var sections [][]byte
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+	for {
+		section, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading manifest: %v", err)
+		}
+		if len(bytes.TrimSpace(section)) == 0 {
+			continue
+		}
+		sections = append(sections, section)
+	}
+
+	return sections, nil
+}
+
+// suppressEquivalentManifest ignores formatting, comment and document order differences by comparing
+// both manifests once parsed and rendered the same way. Invalid manifests are never suppressed
+func suppressEquivalentManifest(k, old, new string, d *schema.ResourceData) bool {
+
+	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
+	if err != nil {
+		return false
+	}
+
+	return equivalentManifests(old, new, registryBase)
+}
+
+// equivalentManifests reports whether a and b describe the same cluster and instance groups
+func equivalentManifests(a, b string, registryBase vfs.Path) bool {
+
+	var formatted []string
+	for _, manifest := range []string{a, b} {
+		cluster, instanceGroups, err := parseClusterManifest(manifest, registryBase)
+		if err != nil {
+			return false
+		}
+		f, err := formatClusterManifest(cluster, instanceGroups)
+		if err != nil {
+			return false
+		}
+		formatted = append(formatted, f)
+	}
+
+	return formatted[0] == formatted[1]
+}
+
+// formatClusterManifest renders the cluster and instance groups as versioned YAML, sorted by instance
+// group name. Fields set by the state store are cleared so stored and desired manifests can be compared
+func formatClusterManifest(cluster *api.Cluster, instanceGroups []*api.InstanceGroup) (string, error) {
+
+	sorted := make([]*api.InstanceGroup, len(instanceGroups))
+	copy(sorted, instanceGroups)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ObjectMeta.Name < sorted[j].ObjectMeta.Name
+	})
+
+	c := cluster.DeepCopy()
+	c.ObjectMeta.CreationTimestamp = metav1.Time{}
+
+	b, err := kopscodecs.ToVersionedYaml(c)
+	if err != nil {
+		return "", fmt.Errorf("error formatting cluster %q: %v", cluster.ObjectMeta.Name, err)
+	}
+	sections := []string{string(b)}
+
+	for _, ig := range sorted {
+		g := ig.DeepCopy()
+		g.ObjectMeta.CreationTimestamp = metav1.Time{}
+		delete(g.ObjectMeta.Labels, managedByLabel)
+
+		b, err := kopscodecs.ToVersionedYaml(g)
+		if err != nil {
+			return "", fmt.Errorf("error formatting InstanceGroup %q: %v", ig.ObjectMeta.Name, err)
+		}
+		sections = append(sections, string(b))
+	}
+
+	return strings.Join(sections, "\n---\n"), nil
+}
@@ -0,0 +1,114 @@
+package kops
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/kops/util/pkg/vfs"
+)
+
+func TestSplitManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "single document",
+			manifest: "kind: Cluster\n",
+			want:     []string{"kind: Cluster"},
+		},
+		{
+			name:     "leading separator",
+			manifest: "---\nkind: Cluster\n---\nkind: InstanceGroup\n",
+			want:     []string{"kind: Cluster", "kind: InstanceGroup"},
+		},
+		{
+			name:     "CRLF line endings",
+			manifest: "kind: Cluster\r\n---\r\nkind: InstanceGroup\r\n",
+			want:     []string{"kind: Cluster", "kind: InstanceGroup"},
+		},
+		{
+			name:     "separator with trailing spaces",
+			manifest: "kind: Cluster\n---  \nkind: InstanceGroup\n",
+			want:     []string{"kind: Cluster", "kind: InstanceGroup"},
+		},
+		{
+			name:     "empty documents",
+			manifest: "---\n\n---\nkind: Cluster\n---\n",
+			want:     []string{"kind: Cluster"},
+		},
+	}
+
+	for _, tc := range tests {
+		sections, err := splitManifest(tc.manifest)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		var got []string
+		for _, section := range sections {
+			got = append(got, strings.TrimSpace(string(section)))
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseClusterManifestErrors(t *testing.T) {
+	instanceGroup := `apiVersion: kops/v1alpha2
+kind: InstanceGroup
+metadata:
+  name: nodes
+spec:
+  role: Node
+`
+	cluster := `apiVersion: kops/v1alpha2
+kind: Cluster
+metadata:
+  name: test.example.com
+spec:
+  cloudProvider: aws
+`
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "no cluster",
+			manifest: instanceGroup,
+			want:     "must contain a Cluster",
+		},
+		{
+			name:     "no instance group",
+			manifest: cluster,
+			want:     "at least one InstanceGroup",
+		},
+		{
+			name:     "two clusters",
+			manifest: cluster + "---\n" + cluster + "---\n" + instanceGroup,
+			want:     "single Cluster",
+		},
+		{
+			name:     "not yaml",
+			manifest: "kind: [",
+			want:     "error parsing manifest",
+		},
+	}
+
+	for _, tc := range tests {
+		_, _, err := parseClusterManifest(tc.manifest, vfs.NewFSPath("/state"))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestEquivalentManifestsInvalid(t *testing.T) {
+	if equivalentManifests("kind: [", "kind: [", vfs.NewFSPath("/state")) {
+		t.Errorf("invalid manifests must never be suppressed")
+	}
+}
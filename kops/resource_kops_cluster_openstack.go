@@ -0,0 +1,88 @@
+package kops
+
+import (
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// expandOpenstackConfiguration builds the openstack cloud config from the openstack block, nil when
+// the block is omitted
+func expandOpenstackConfiguration(l []interface{}) *api.OpenstackConfiguration {
+
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+
+	config := &api.OpenstackConfiguration{}
+	config.Router = &api.OpenstackRouter{}
+	if v := m["router_external_network"].(string); v != "" {
+		config.Router.ExternalNetwork = fi.String(v)
+	}
+	if v := m["router_external_subnet"].(string); v != "" {
+		config.Router.ExternalSubnet = fi.String(v)
+	}
+	if v := m["router_dns_servers"].(string); v != "" {
+		config.Router.DNSServers = fi.String(v)
+	}
+
+	if v := m["loadbalancer_floating_network"].(string); v != "" {
+		config.Loadbalancer = &api.OpenstackLoadbalancerConfig{
+			FloatingNetwork: fi.String(v),
+			Method:          fi.String(m["loadbalancer_method"].(string)),
+			Provider:        fi.String(m["loadbalancer_provider"].(string)),
+			UseOctavia:      fi.Bool(m["loadbalancer_use_octavia"].(bool)),
+		}
+		if subnetID := m["loadbalancer_subnet_id"].(string); subnetID != "" {
+			config.Loadbalancer.SubnetID = fi.String(subnetID)
+		}
+	}
+
+	config.BlockStorage = &api.OpenstackBlockStorageConfig{
+		IgnoreAZ: fi.Bool(m["block_storage_ignore_az"].(bool)),
+	}
+	if v := m["block_storage_version"].(string); v != "" {
+		config.BlockStorage.Version = fi.String(v)
+	}
+	return config
+}
+
+// flattenOpenstackConfiguration is the inverse of expandOpenstackConfiguration. Every attribute is
+// set so the block hashes the same as the configuration, the load balancer ones falling back to
+// their schema defaults when there is no load balancer
+func flattenOpenstackConfiguration(config *api.OpenstackConfiguration) []interface{} {
+
+	if config == nil {
+		return nil
+	}
+
+	m := map[string]interface{}{
+		"router_external_network":       "",
+		"router_external_subnet":        "",
+		"router_dns_servers":            "",
+		"loadbalancer_floating_network": "",
+		"loadbalancer_subnet_id":        "",
+		"loadbalancer_method":           "ROUND_ROBIN",
+		"loadbalancer_provider":         "haproxy",
+		"loadbalancer_use_octavia":      false,
+		"block_storage_version":         "",
+		"block_storage_ignore_az":       false,
+	}
+	if config.Router != nil {
+		m["router_external_network"] = fi.StringValue(config.Router.ExternalNetwork)
+		m["router_external_subnet"] = fi.StringValue(config.Router.ExternalSubnet)
+		m["router_dns_servers"] = fi.StringValue(config.Router.DNSServers)
+	}
+	if config.Loadbalancer != nil {
+		m["loadbalancer_floating_network"] = fi.StringValue(config.Loadbalancer.FloatingNetwork)
+		m["loadbalancer_subnet_id"] = fi.StringValue(config.Loadbalancer.SubnetID)
+		m["loadbalancer_method"] = fi.StringValue(config.Loadbalancer.Method)
+		m["loadbalancer_provider"] = fi.StringValue(config.Loadbalancer.Provider)
+		m["loadbalancer_use_octavia"] = fi.BoolValue(config.Loadbalancer.UseOctavia)
+	}
+	if config.BlockStorage != nil {
+		m["block_storage_version"] = fi.StringValue(config.BlockStorage.Version)
+		m["block_storage_ignore_az"] = fi.BoolValue(config.BlockStorage.IgnoreAZ)
+	}
+	return []interface{}{m}
+}
@@ -0,0 +1,70 @@
+package kops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestOpenstackConfigurationRoundTrip(t *testing.T) {
+	hash := schema.HashResource(kopsSchema()["openstack"].Elem.(*schema.Resource))
+
+	if config := expandOpenstackConfiguration(nil); config != nil {
+		t.Errorf("omitted block: got %+v, want nil", config)
+	}
+	if l := flattenOpenstackConfiguration(nil); l != nil {
+		t.Errorf("omitted block: got %v, want nil", l)
+	}
+
+	// Blocks as Terraform reads them from the configuration, with the schema defaults filled in
+	cases := []struct {
+		name string
+		m    map[string]interface{}
+	}{
+		{
+			name: "without load balancer",
+			m: map[string]interface{}{
+				"router_external_network":       "public",
+				"router_external_subnet":        "",
+				"router_dns_servers":            "8.8.8.8",
+				"loadbalancer_floating_network": "",
+				"loadbalancer_subnet_id":        "",
+				"loadbalancer_method":           "ROUND_ROBIN",
+				"loadbalancer_provider":         "haproxy",
+				"loadbalancer_use_octavia":      false,
+				"block_storage_version":         "",
+				"block_storage_ignore_az":       true,
+			},
+		},
+		{
+			name: "with load balancer",
+			m: map[string]interface{}{
+				"router_external_network":       "public",
+				"router_external_subnet":        "",
+				"router_dns_servers":            "",
+				"loadbalancer_floating_network": "public",
+				"loadbalancer_subnet_id":        "subnet-1",
+				"loadbalancer_method":           "LEAST_CONNECTIONS",
+				"loadbalancer_provider":         "octavia",
+				"loadbalancer_use_octavia":      true,
+				"block_storage_version":         "v2",
+				"block_storage_ignore_az":       false,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		l := flattenOpenstackConfiguration(expandOpenstackConfiguration([]interface{}{c.m}))
+		if len(l) != 1 {
+			t.Fatalf("%s: got %v, want one block", c.name, l)
+		}
+		flattened := l[0].(map[string]interface{})
+		if !reflect.DeepEqual(flattened, c.m) {
+			t.Errorf("%s: got %v, want %v", c.name, flattened, c.m)
+		}
+		if hash(flattened) != hash(c.m) {
+			t.Errorf("%s: hash changed on round trip", c.name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package kops
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "k8s.io/kops/pkg/apis/kops"
+)
+
+func TestReadCompletedCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kops-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cluster := &api.Cluster{}
+	cluster.ObjectMeta.Name = "test.example.com"
+	cluster.Spec.ConfigBase = filepath.Join(dir, cluster.ObjectMeta.Name)
+
+	fullCluster, err := readCompletedCluster(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error before the first apply: %v", err)
+	}
+	if fullCluster != nil {
+		t.Fatalf("got %v before the first apply, want nil", fullCluster)
+	}
+
+	spec := `metadata:
+  name: test.example.com
+spec:
+  subnets:
+  - name: us-east-1a
+    zone: us-east-1a
+    cidr: 172.20.32.0/19
+    type: Public
+`
+	if err := os.MkdirAll(cluster.Spec.ConfigBase, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cluster.Spec.ConfigBase, "cluster.spec"), []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fullCluster, err = readCompletedCluster(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fullCluster == nil || len(fullCluster.Spec.Subnets) != 1 || fullCluster.Spec.Subnets[0].CIDR != "172.20.32.0/19" {
+		t.Errorf("got %+v, want the stored subnet with its CIDR", fullCluster)
+	}
+}
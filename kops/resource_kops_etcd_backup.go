@@ -0,0 +1,257 @@
+package kops
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	api "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/apis/kops/registry"
+	"k8s.io/kops/pkg/client/simple/vfsclientset"
+	"k8s.io/kops/util/pkg/vfs"
+)
+
+// etcd-manager polls <backup store>/control for commands, the same files etcd-manager-ctl writes
+const (
+	etcdControlDir      = "control"
+	etcdCommandFilename = "_command.json"
+)
+
+type etcdClusterSpec struct {
+	MemberCount int32  `json:"memberCount"`
+	EtcdVersion string `json:"etcdVersion"`
+}
+
+type etcdRestoreBackupCommand struct {
+	ClusterSpec *etcdClusterSpec `json:"clusterSpec"`
+	Backup      string           `json:"backup"`
+}
+
+type etcdCommand struct {
+	Timestamp     int64                     `json:"timestamp"`
+	RestoreBackup *etcdRestoreBackupCommand `json:"restoreBackup,omitempty"`
+}
+
+func resourceKopsEtcdBackup() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKopsEtcdBackupCreate,
+		Read:   resourceKopsEtcdBackupRead,
+		Delete: resourceKopsEtcdBackupDelete,
+		Schema: kopsEtcdBackupSchema(),
+	}
+}
+
+// etcd-manager backs up on its own schedule and has no command to request a backup, so lookup mode
+// only records the latest existing backup. Restore mode stages a restore the same as etcd-manager-ctl restore-backup
+func resourceKopsEtcdBackupCreate(d *schema.ResourceData, meta interface{}) error {
+
+	clusterName := d.Get("cluster_name").(string)
+	mode := d.Get("mode").(string)
+
+	etcdCluster, backupStore, err := getEtcdBackupStore(d)
+	if err != nil {
+		return err
+	}
+
+	backups, err := listEtcdBackups(backupStore)
+	if err != nil {
+		return err
+	}
+
+	backupName := d.Get("backup_name").(string)
+	if backupName == "" {
+		if mode == "restore" {
+			return fmt.Errorf("backup_name is required to restore")
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf("no backups found in %s", backupStore.Path())
+		}
+		backupName = backups[len(backups)-1]
+	}
+
+	found := false
+	for _, b := range backups {
+		if b == backupName {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("backup %q not found in %s", backupName, backupStore.Path())
+	}
+
+	if mode == "restore" {
+		if etcdCluster.Provider != api.EtcdProviderTypeManager {
+			return fmt.Errorf("restore needs etcd cluster %q to use the Manager provider", etcdCluster.Name)
+		}
+		if err := stageEtcdRestore(backupStore, etcdCluster, backupName); err != nil {
+			return err
+		}
+		log.Printf("[INFO] Staged restore of %s for etcd cluster %s of Kops Cluster %s", backupName, etcdCluster.Name, clusterName)
+	}
+
+	d.Set("backup_name", backupName)
+	d.SetId(clusterName + "/" + etcdCluster.Name + "/" + backupName)
+
+	return resourceKopsEtcdBackupRead(d, meta)
+}
+
+func resourceKopsEtcdBackupRead(d *schema.ResourceData, meta interface{}) error {
+
+	_, backupStore, err := getEtcdBackupStore(d)
+	if err != nil {
+		return err
+	}
+
+	backups, err := listEtcdBackups(backupStore)
+	if err != nil {
+		return err
+	}
+
+	// etcd-manager expires old backups, the recorded name is kept so that doesn't force a replacement
+	found := false
+	for _, b := range backups {
+		if b == d.Get("backup_name").(string) {
+			found = true
+		}
+	}
+	if !found {
+		log.Printf("[WARN] Etcd backup %s is no longer in %s", d.Id(), backupStore.Path())
+	}
+
+	d.Set("backup_store", backupStore.Path())
+	d.Set("backups", backups)
+
+	return nil
+}
+
+// Backups are left in the backup store, etcd-manager expires them itself
+func resourceKopsEtcdBackupDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
+
+// getEtcdBackupStore returns the etcd cluster spec and where its backups are kept. Without an
+// explicit backup store etcd-manager uses backups/etcd/<name> under the cluster's config base
+func getEtcdBackupStore(d *schema.ResourceData) (*api.EtcdClusterSpec, vfs.Path, error) {
+
+	name := d.Get("cluster_name").(string)
+
+	registryBase, err := vfs.Context.BuildVfsPath(d.Get("state_store").(string))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing registry path %q: %v", d.Get("state_store").(string), err)
+	}
+	allowList := true
+
+	clientset := vfsclientset.NewVFSClientset(registryBase, allowList)
+
+	log.Printf("[INFO] Reading Kops Cluster %s", name)
+	cluster, err := clientset.GetCluster(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var etcdCluster *api.EtcdClusterSpec
+	for _, e := range cluster.Spec.EtcdClusters {
+		if e.Name == d.Get("etcd_cluster").(string) {
+			etcdCluster = e
+		}
+	}
+	if etcdCluster == nil {
+		return nil, nil, fmt.Errorf("etcd cluster %q not found in cluster %q", d.Get("etcd_cluster").(string), name)
+	}
+
+	if etcdCluster.Backups != nil && etcdCluster.Backups.BackupStore != "" {
+		backupStore, err := vfs.Context.BuildVfsPath(etcdCluster.Backups.BackupStore)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing backup store %q: %v", etcdCluster.Backups.BackupStore, err)
+		}
+		return etcdCluster, backupStore, nil
+	}
+
+	configBase, err := registry.ConfigBase(cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	return etcdCluster, configBase.Join("backups", "etcd", etcdCluster.Name), nil
+}
+
+// listEtcdBackups returns the names of the backups in backupStore, which sort oldest first. Object
+// stores have no directories to list, so the backups are the first path segments of the files
+func listEtcdBackups(backupStore vfs.Path) ([]string, error) {
+
+	files, err := backupStore.ReadTree()
+	if err != nil {
+		return nil, fmt.Errorf("error listing backups in %s: %v", backupStore.Path(), err)
+	}
+
+	var paths []string
+	for _, f := range files {
+		p, err := vfs.RelativePath(backupStore, f)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return etcdBackupNames(paths), nil
+}
+
+// etcdBackupNames returns the sorted backup names in paths relative to the backup store, leaving out
+// the control directory and files outside of a backup
+func etcdBackupNames(paths []string) []string {
+
+	seen := make(map[string]bool)
+	backups := []string{}
+	for _, p := range paths {
+		i := strings.Index(p, "/")
+		if i <= 0 {
+			continue
+		}
+		name := p[:i]
+		if name == etcdControlDir || seen[name] {
+			continue
+		}
+		seen[name] = true
+		backups = append(backups, name)
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// stageEtcdRestore writes a restore-backup command for etcd-manager to pick up
+func stageEtcdRestore(backupStore vfs.Path, etcdCluster *api.EtcdClusterSpec, backupName string) error {
+
+	now := time.Now().UTC()
+	cmd := &etcdCommand{
+		Timestamp: now.UnixNano(),
+		RestoreBackup: &etcdRestoreBackupCommand{
+			ClusterSpec: &etcdClusterSpec{
+				MemberCount: int32(len(etcdCluster.Members)),
+				EtcdVersion: etcdCluster.Version,
+			},
+			Backup: backupName,
+		},
+	}
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	p := backupStore.Join(etcdControlDir, now.Format(time.RFC3339Nano)+"-"+hex.EncodeToString(suffix), etcdCommandFilename)
+	if err := p.WriteFile(bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("error writing restore command to %s: %v", p.Path(), err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package kops
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/kops/util/pkg/vfs"
+)
+
+func TestEtcdBackupNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{
+			name: "none",
+			want: []string{},
+		},
+		{
+			name: "one name per backup, sorted",
+			paths: []string{
+				"2019-01-02T00:00:00Z-000002/etcd.backup.gz",
+				"2019-01-01T00:00:00Z-000001/_etcd_backup.meta",
+				"2019-01-01T00:00:00Z-000001/etcd.backup.gz",
+				"2019-01-02T00:00:00Z-000002/_etcd_backup.meta",
+			},
+			want: []string{"2019-01-01T00:00:00Z-000001", "2019-01-02T00:00:00Z-000002"},
+		},
+		{
+			name: "control commands and loose files are skipped",
+			paths: []string{
+				"control/2019-01-03T00:00:00Z-abcd/_command.json",
+				"control/etcd-cluster-spec",
+				"README",
+				"2019-01-01T00:00:00Z-000001/etcd.backup.gz",
+			},
+			want: []string{"2019-01-01T00:00:00Z-000001"},
+		},
+	}
+
+	for _, tc := range tests {
+		got := etcdBackupNames(tc.paths)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestListEtcdBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{
+		"2019-01-01T00:00:00Z-000001/etcd.backup.gz",
+		"2019-01-01T00:00:00Z-000001/_etcd_backup.meta",
+		"control/etcd-cluster-spec",
+	} {
+		p := vfs.NewFSPath(filepath.Join(dir, f))
+		if err := p.WriteFile(bytes.NewReader([]byte("{}")), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := listEtcdBackups(vfs.NewFSPath(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"2019-01-01T00:00:00Z-000001"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
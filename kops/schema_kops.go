@@ -1,6 +1,11 @@
 package kops
 
-import "github.com/hashicorp/terraform/helper/schema"
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
 
 func kopsSchema() map[string]*schema.Schema {
 	return map[string]*schema.Schema{
@@ -27,12 +32,89 @@ func kopsSchema() map[string]*schema.Schema {
 			Description: "Currently only supported in AWS. Sets the ARN of the SSL Certificate to use for the API server loadbalancer",
 			Optional:    true,
 		},
+		"authentication": {
+			Type:        schema.TypeSet,
+			Description: "Authentication plugin for the API server",
+			Optional:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:         schema.TypeString,
+						Description:  "aws (aws-iam-authenticator) or kopeio",
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"aws", "kopeio"}, false),
+					},
+				},
+			},
+		},
+		"authentication_config_map": {
+			Type:        schema.TypeString,
+			Description: "The aws-iam-authenticator ConfigMap, rendered from the mappings read back from the cluster",
+			Computed:    true,
+		},
+		"authentication_role_mapping": {
+			Type:        schema.TypeList,
+			Description: "IAM roles mapped to kubernetes users, written to the aws-iam-authenticator ConfigMap",
+			Optional:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"arn": {
+						Type:        schema.TypeString,
+						Description: "ARN of the IAM role",
+						Required:    true,
+					},
+					"username": {
+						Type:        schema.TypeString,
+						Description: "Kubernetes user name",
+						Required:    true,
+					},
+					"groups": {
+						Type:        schema.TypeList,
+						Description: "Kubernetes groups e.g. system:masters",
+						Optional:    true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+				},
+			},
+		},
+		"authentication_user_mapping": {
+			Type:        schema.TypeList,
+			Description: "IAM users mapped to kubernetes users, written to the aws-iam-authenticator ConfigMap",
+			Optional:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"arn": {
+						Type:        schema.TypeString,
+						Description: "ARN of the IAM user",
+						Required:    true,
+					},
+					"username": {
+						Type:        schema.TypeString,
+						Description: "Kubernetes user name",
+						Required:    true,
+					},
+					"groups": {
+						Type:        schema.TypeList,
+						Description: "Kubernetes groups e.g. system:masters",
+						Optional:    true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+				},
+			},
+		},
 		"authorization": {
 			Type:        schema.TypeString,
-			Description: "Authorization, RBAC or AlwaysAllow",
-			ForceNew:    true,
+			Description: "Authorization, RBAC or AlwaysAllow. Changing it replaces the masters",
 			Optional:    true,
 			Default:     "AlwaysAllow",
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				return strings.EqualFold(old, new)
+			},
 		},
 		"bastion": {
 			Type:        schema.TypeBool,
@@ -40,9 +122,109 @@ func kopsSchema() map[string]*schema.Schema {
 			Optional:    true,
 			Default:     false,
 		},
+		"bastion_additional_policies": {
+			Type:         schema.TypeString,
+			Description:  "JSON array of IAM policy statements added to the bastion role",
+			Optional:     true,
+			ValidateFunc: validation.ValidateJsonString,
+		},
+		"bastion_config": {
+			Type:        schema.TypeSet,
+			Description: "Settings for the bastion instance group, requires bastion = true",
+			Optional:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"additional_security_groups": {
+						Type:        schema.TypeList,
+						Description: "Precreated security groups to add to the bastion instances",
+						Optional:    true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+					"allowed_cidrs": {
+						Type:        schema.TypeList,
+						Description: "CIDRs allowed to SSH to the bastion, sets ssh_access",
+						Optional:    true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+					"associate_public_ip": {
+						Type:     schema.TypeBool,
+						Optional: true,
+						Default:  false,
+					},
+					"idle_timeout": {
+						Type:        schema.TypeInt,
+						Description: "Idle timeout of the bastion load balancer in seconds",
+						Optional:    true,
+					},
+					"load_balancer_security_groups": {
+						Type:        schema.TypeList,
+						Description: "Precreated security groups to add to the bastion load balancer",
+						Optional:    true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+					"machine_type": {
+						Type:        schema.TypeString,
+						Description: "Bastion Instance Size e.g. t2.micro",
+						Optional:    true,
+					},
+					"max_size": {
+						Type:     schema.TypeInt,
+						Optional: true,
+						Default:  1,
+					},
+					"min_size": {
+						Type:     schema.TypeInt,
+						Optional: true,
+						Default:  1,
+					},
+					"public_name": {
+						Type:        schema.TypeString,
+						Description: "DNS name of the bastion, defaults to bastion.<name>",
+						Optional:    true,
+					},
+				},
+			},
+		},
+		"bastion_iam_instance_profile": {
+			Type:        schema.TypeString,
+			Description: "ARN of an existing instance profile for the bastion instances, instead of the one kops creates",
+			Optional:    true,
+		},
+		"bastion_image": {
+			Type:        schema.TypeString,
+			Description: "Image for the bastion instance group, defaults to image",
+			Optional:    true,
+		},
+		"bastion_volume_size": {
+			Type:        schema.TypeInt,
+			Description: "Bastion Root Volume Size",
+			Optional:    true,
+		},
+		"bastion_volume_iops": {
+			Type:        schema.TypeInt,
+			Description: "Bastion Root Volume IOPS, for io1 volumes",
+			Optional:    true,
+		},
+		"bastion_volume_optimization": {
+			Type:        schema.TypeBool,
+			Description: "Enable EBS optimization for bastion instances",
+			Optional:    true,
+		},
+		"bastion_volume_type": {
+			Type:        schema.TypeString,
+			Description: "Bastion Root Volume Type e.g. gp2 or io1. Root volumes can't be encrypted with a KMS key on kops 1.11, use an AMI with encrypted snapshots",
+			Optional:    true,
+		},
 		"cloud": {
 			Type:        schema.TypeString,
-			Description: "Name of Cloud Provider",
+			Description: "Name of Cloud Provider, aws, gce, digitalocean or openstack",
 			Optional:    true,
 			ForceNew:    true,
 			Default:     "aws",
@@ -52,11 +234,77 @@ func kopsSchema() map[string]*schema.Schema {
 			Description: "A list of KV pairs used to tag all instance groups in AWS (eg Owner=John Doe,Team=Some Team)",
 			Optional:    true,
 		},
+		"cluster_spec_patch": {
+			Type:         schema.TypeString,
+			Description:  "JSON patch applied to the Cluster before it is saved, for fields not covered by this resource e.g. {\"spec\":{\"docker\":{\"logLevel\":\"warn\"}}}",
+			Optional:     true,
+			ValidateFunc: validation.ValidateJsonString,
+		},
+		"cluster_subnets": {
+			Type:        schema.TypeList,
+			Description: "Subnets of the completed cluster spec, including the CIDRs kops assigned",
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"zone": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"region": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"cidr": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"type": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"provider_id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+				},
+			},
+		},
 		"config": {
 			Type:        schema.TypeString,
 			Description: "yaml config file(default is $HOME/.kops.yaml)",
 			Computed:    true,
 		},
+		"delete_preview": {
+			Type:        schema.TypeList,
+			Description: "Cloud resources that would be removed when the cluster is destroyed, listed only with enable_delete_preview",
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+				},
+			},
+		},
+		"deletion_protection": {
+			Type:        schema.TypeBool,
+			Description: "Prevent the cluster from being destroyed",
+			Optional:    true,
+			Default:     false,
+		},
 		"dns": {
 			Type:        schema.TypeString,
 			Description: "DNS hosted zone to use: public|private. (default Public)",
@@ -68,22 +316,148 @@ func kopsSchema() map[string]*schema.Schema {
 			Optional:    true,
 			Default:     "false",
 		},
+		"enable_delete_preview": {
+			Type:        schema.TypeBool,
+			Description: "List the cloud resources in delete_preview on every refresh, which queries the cloud provider APIs each time",
+			Optional:    true,
+			Default:     false,
+		},
 		"encrypt_etcd_storage": {
 			Type:        schema.TypeBool,
 			Description: "Generate key in aws kms and use it for encrypt etcd volume",
 			Optional:    true,
 		},
+		"etcd_cluster": {
+			Type:        schema.TypeSet,
+			Description: "Settings for the main and events etcd clusters",
+			Optional:    true,
+			MaxItems:    2,
+			// Durations are read back in Go's format, e.g. 1200ms as 1.2s, so they are hashed the same way
+			Set: etcdClusterHash,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:         schema.TypeString,
+						Description:  "main or events",
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"main", "events"}, false),
+					},
+					"provider": {
+						Type:         schema.TypeString,
+						Description:  "Legacy or Manager (etcd-manager)",
+						Optional:     true,
+						ValidateFunc: validation.StringInSlice([]string{"", "Legacy", "Manager"}, false),
+					},
+					"version": {
+						Type:        schema.TypeString,
+						Description: "Overrides etcd_version for this cluster",
+						Optional:    true,
+					},
+					"image": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"backup_store": {
+						Type:        schema.TypeString,
+						Description: "VFS path backups are written to e.g. s3://bucket/cluster/backups/etcd/main",
+						Optional:    true,
+					},
+					"backup_image": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"volume_type": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"volume_size": {
+						Type:        schema.TypeInt,
+						Description: "Volume size in GB",
+						Optional:    true,
+					},
+					"volume_iops": {
+						Type:     schema.TypeInt,
+						Optional: true,
+					},
+					"kms_key_id": {
+						Type:        schema.TypeString,
+						Description: "KMS key used to encrypt the volumes, implies encryption",
+						Optional:    true,
+					},
+					"heartbeat_interval": {
+						Type:             schema.TypeString,
+						Description:      "e.g. 250ms",
+						Optional:         true,
+						ValidateFunc:     validateDuration,
+						DiffSuppressFunc: suppressEquivalentDuration,
+					},
+					"leader_election_timeout": {
+						Type:             schema.TypeString,
+						Description:      "e.g. 1200ms",
+						Optional:         true,
+						ValidateFunc:     validateDuration,
+						DiffSuppressFunc: suppressEquivalentDuration,
+					},
+					"enable_etcd_tls": {
+						Type:     schema.TypeBool,
+						Optional: true,
+					},
+					"enable_tls_auth": {
+						Type:     schema.TypeBool,
+						Optional: true,
+					},
+				},
+			},
+		},
 		"etcd_version": {
 			Type:        schema.TypeString,
 			Description: "etcd version",
 			Optional:    true,
 			Default:     "3.2.24",
 		},
+		"full_spec_yaml": {
+			Type:        schema.TypeString,
+			Description: "The completed cluster spec kops runs with, the same as kops get cluster --full -o yaml",
+			Computed:    true,
+		},
+		"hibernated": {
+			Type:        schema.TypeBool,
+			Description: "Scale the node and bastion instance groups to zero, keeping the masters and the cluster. The configured sizes are recorded in the state store and applied again when unset, so sizes can be changed while hibernated",
+			Optional:    true,
+			Default:     false,
+		},
+		"iam": {
+			Type:        schema.TypeSet,
+			Description: "IAM settings for the roles kops creates, aws only",
+			Optional:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"allow_container_registry": {
+						Type:        schema.TypeBool,
+						Description: "Allow the instances to pull from ECR",
+						Optional:    true,
+						Default:     true,
+					},
+					"legacy": {
+						Type:        schema.TypeBool,
+						Description: "Use the legacy, broader, IAM policies",
+						Optional:    true,
+						Default:     false,
+					},
+				},
+			},
+		},
 		"image": {
 			Type:        schema.TypeString,
-			Description: "AMI Image for all volumes",
+			Description: "Image for all instance groups, e.g. an AMI on aws or cos-cloud/cos-stable-65-10323-64-0 on gce. Defaults to the image of the stable channel",
+			Optional:    true,
+			Computed:    true,
+		},
+		"instance_group_spec_patch": {
+			Type:        schema.TypeMap,
+			Description: "JSON patches applied to instance groups before they are saved, keyed by instance group name e.g. nodes. With node_group_per_zone a nodes patch applies to every nodes-<zone> group, use nodes-<zone> to patch a single zone",
 			Optional:    true,
-			Default:     "ami-03b850a018c8cd25e",
 		},
 		"k8s_version": {
 			Type:        schema.TypeString,
@@ -120,10 +494,30 @@ func kopsSchema() map[string]*schema.Schema {
 				},
 			},
 		},
+		"lifecycle_overrides": {
+			Type:        schema.TypeMap,
+			Description: "Lifecycle of kops tasks keyed by task type e.g. SecurityGroup = \"Ignore\", one of Sync, Ignore, WarnIfInsufficientAccess or ExistsAndValidates",
+			Optional:    true,
+		},
+		"master_additional_policies": {
+			Type:         schema.TypeString,
+			Description:  "JSON array of IAM policy statements added to the master role",
+			Optional:     true,
+			ValidateFunc: validation.ValidateJsonString,
+		},
+		"master_iam_instance_profile": {
+			Type:        schema.TypeString,
+			Description: "ARN of an existing instance profile for the master instances, instead of the one kops creates",
+			Optional:    true,
+		},
+		"master_image": {
+			Type:        schema.TypeString,
+			Description: "Image for the master instance groups, defaults to image",
+			Optional:    true,
+		},
 		"master_per_zone": {
 			Type:        schema.TypeInt,
-			Description: "Masters Per Zone",
-			ForceNew:    true,
+			Description: "Masters Per Zone, each master gets its own instance group named master-<zone>-a, -b, ... The total number of masters must be odd. It can't be changed once the cluster exists, and clusters that run several masters in one master-<zone> group keep that layout",
 			Optional:    true,
 			Default:     1,
 		},
@@ -143,9 +537,23 @@ func kopsSchema() map[string]*schema.Schema {
 		"master_volume_size": {
 			Type:        schema.TypeInt,
 			Description: "Master Root Volume Size",
-			ForceNew:    true,
 			Required:    true,
 		},
+		"master_volume_iops": {
+			Type:        schema.TypeInt,
+			Description: "Master Root Volume IOPS, for io1 volumes",
+			Optional:    true,
+		},
+		"master_volume_optimization": {
+			Type:        schema.TypeBool,
+			Description: "Enable EBS optimization for master instances",
+			Optional:    true,
+		},
+		"master_volume_type": {
+			Type:        schema.TypeString,
+			Description: "Master Root Volume Type e.g. gp2 or io1. Root volumes can't be encrypted with a KMS key on kops 1.11, use an AMI with encrypted snapshots",
+			Optional:    true,
+		},
 		"master_zones": {
 			Type:        schema.TypeList,
 			Description: "Zones in which to run masters (must be an odd number)",
@@ -180,16 +588,36 @@ func kopsSchema() map[string]*schema.Schema {
 			ForceNew:    true,
 			Default:     "kubenet",
 		},
+		"node_additional_policies": {
+			Type:         schema.TypeString,
+			Description:  "JSON array of IAM policy statements added to the node role",
+			Optional:     true,
+			ValidateFunc: validation.ValidateJsonString,
+		},
+		"node_group_per_zone": {
+			Type:        schema.TypeBool,
+			Description: "Create a nodes-<zone> instance group per node zone instead of one nodes group, node_min_size and node_max_size are spread across them. Other node groups, e.g. added with kops create ig, are not read back",
+			Optional:    true,
+			Default:     false,
+		},
+		"node_iam_instance_profile": {
+			Type:        schema.TypeString,
+			Description: "ARN of an existing instance profile for the node instances, instead of the one kops creates",
+			Optional:    true,
+		},
+		"node_image": {
+			Type:        schema.TypeString,
+			Description: "Image for the nodes instance group, defaults to image",
+			Optional:    true,
+		},
 		"node_max_size": {
 			Type:        schema.TypeInt,
 			Description: "Node Max Size",
-			ForceNew:    true,
 			Required:    true,
 		},
 		"node_min_size": {
 			Type:        schema.TypeInt,
 			Description: "Node Min Size",
-			ForceNew:    true,
 			Required:    true,
 		},
 		"node_size": {
@@ -200,7 +628,6 @@ func kopsSchema() map[string]*schema.Schema {
 		"node_volume_size": {
 			Type:        schema.TypeInt,
 			Description: "Node Root Volume Size",
-			ForceNew:    true,
 			Required:    true,
 		},
 		"node_security_groups": {
@@ -212,6 +639,21 @@ func kopsSchema() map[string]*schema.Schema {
 				Type:     schema.TypeString,
 				MinItems: 1},
 		},
+		"node_volume_iops": {
+			Type:        schema.TypeInt,
+			Description: "Node Root Volume IOPS, for io1 volumes",
+			Optional:    true,
+		},
+		"node_volume_optimization": {
+			Type:        schema.TypeBool,
+			Description: "Enable EBS optimization for node instances",
+			Optional:    true,
+		},
+		"node_volume_type": {
+			Type:        schema.TypeString,
+			Description: "Node Root Volume Type e.g. gp2 or io1. Root volumes can't be encrypted with a KMS key on kops 1.11, use an AMI with encrypted snapshots",
+			Optional:    true,
+		},
 		"node_zones": {
 			Type:        schema.TypeList,
 			Description: "The list of node zones",
@@ -227,6 +669,59 @@ func kopsSchema() map[string]*schema.Schema {
 			Optional:    true,
 			Default:     "100.64.0.1/10",
 		},
+		"openstack": {
+			Type:        schema.TypeSet,
+			Description: "OpenStack router, load balancer and block storage settings. Only applies when cloud is openstack",
+			Optional:    true,
+			ForceNew:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"router_external_network": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"router_external_subnet": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"router_dns_servers": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"loadbalancer_floating_network": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"loadbalancer_subnet_id": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"loadbalancer_method": {
+						Type:     schema.TypeString,
+						Optional: true,
+						Default:  "ROUND_ROBIN",
+					},
+					"loadbalancer_provider": {
+						Type:     schema.TypeString,
+						Optional: true,
+						Default:  "haproxy",
+					},
+					"loadbalancer_use_octavia": {
+						Type:     schema.TypeBool,
+						Optional: true,
+					},
+					"block_storage_version": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"block_storage_ignore_az": {
+						Type:     schema.TypeBool,
+						Optional: true,
+					},
+				},
+			},
+		},
 		"out": {
 			Type:        schema.TypeString,
 			Description: "Path to write any local output",
@@ -237,6 +732,43 @@ func kopsSchema() map[string]*schema.Schema {
 			Description: "Output format.One of json | yaml.Used with the dry-run",
 			Optional:    true,
 		},
+		"phase": {
+			Type:         schema.TypeString,
+			Description:  "Only apply this phase, network, security or cluster, the same as kops update cluster --phase. Empty applies everything. The phase stays set until removed from the configuration, so later applies keep running only that phase",
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{"", "network", "security", "cluster"}, false),
+		},
+		"project": {
+			Type:        schema.TypeString,
+			Description: "GCE project the cluster runs in, required when cloud is gce",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Description: "Region of the subnets, used by digitalocean and openstack",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"rollback_on_failure": {
+			Type:        schema.TypeBool,
+			Description: "Delete the cloud resources and state store files a failed create made. Updates and resumed creates are never rolled back. By default a failed create is resumed on the next apply",
+			Optional:    true,
+			Default:     false,
+		},
+		"rolling_update_cloud_only": {
+			Type:        schema.TypeBool,
+			Description: "Replace instances without draining nodes or validating the cluster, the same as kops rolling-update cluster --cloudonly. Without it updates fail when the kubernetes api cannot be reached",
+			Optional:    true,
+			Default:     false,
+		},
+		"spec_patch_type": {
+			Type:         schema.TypeString,
+			Description:  "How cluster_spec_patch and instance_group_spec_patch are applied, merge (JSON merge patch) or strategic",
+			Optional:     true,
+			Default:      "merge",
+			ValidateFunc: validation.StringInSlice([]string{"merge", "strategic"}, false),
+		},
 		"ssh_access": {
 			Type:        schema.TypeList,
 			Description: "Restrict SSH access to this CIDR.  If not set, access will not be restricted by IP. (default [0.0.0.0/0])",
@@ -253,7 +785,7 @@ func kopsSchema() map[string]*schema.Schema {
 		},
 		"state_store": {
 			Type:        schema.TypeString,
-			Description: "State Store",
+			Description: "State Store, e.g. s3://bucket or gs://bucket",
 			Required:    true,
 			ForceNew:    true,
 		},
@@ -149,6 +149,97 @@ func kopsCloudResourcesSchema() map[string]*schema.Schema {
 				Type: schema.TypeString,
 			},
 		},
+		"load_balancers_id": {
+			Type:     schema.TypeList,
+			Default:  nil,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"resources": {
+			Type:        schema.TypeList,
+			Description: "Every cloud resource kops found for the cluster",
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"shared": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"blocks": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+					"blocked": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Schema{
+							Type: schema.TypeString,
+						},
+					},
+					"instance_group": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"tags": {
+						Type:     schema.TypeMap,
+						Computed: true,
+					},
+				},
+			},
+		},
+		"ids_by_type": {
+			Type:        schema.TypeList,
+			Description: "Resource ids grouped by resource type e.g. autoscaling-group",
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"ids": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem:     &schema.Schema{Type: schema.TypeString},
+					},
+				},
+			},
+		},
+		"ids_by_instance_group": {
+			Type:        schema.TypeList,
+			Description: "Ids of the autoscaling groups, launch configurations and instances of each instance group",
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"instance_group": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"ids": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem:     &schema.Schema{Type: schema.TypeString},
+					},
+				},
+			},
+		},
 		"cluster_name": {
 			Type:        schema.TypeString,
 			Description: "Name of cluster",
@@ -160,6 +251,12 @@ func kopsCloudResourcesSchema() map[string]*schema.Schema {
 			Default:  nil,
 			Computed: true,
 		},
+		"include_shared": {
+			Type:        schema.TypeBool,
+			Description: "Include resources shared with other clusters, e.g. a VPC or subnets set with network_id",
+			Optional:    true,
+			Default:     false,
+		},
 		"internet_gateway_id": {
 			Type:     schema.TypeString,
 			Default:  nil,
@@ -186,6 +283,15 @@ func kopsCloudResourcesSchema() map[string]*schema.Schema {
 			Required:    true,
 			ForceNew:    true,
 		},
+		"types": {
+			Type:        schema.TypeSet,
+			Description: "Only return resources of these types e.g. subnet, security-group",
+			Optional:    true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+			Set: schema.HashString,
+		},
 		"vpc_id": {
 			Type:     schema.TypeString,
 			Default:  nil,
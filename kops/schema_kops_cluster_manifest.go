@@ -0,0 +1,43 @@
+package kops
+
+import "github.com/hashicorp/terraform/helper/schema"
+
+func kopsClusterManifestSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"drift": {
+			Type:        schema.TypeString,
+			Description: "Diff between the manifest and the spec stored in the state store, empty when in sync",
+			Computed:    true,
+		},
+		"manifest": {
+			Type:        schema.TypeString,
+			Description: "Cluster and InstanceGroup YAML documents separated by ---, as produced by kops get -o yaml",
+			Required:    true,
+			// Read stores the manifest the way the state store renders it
+			DiffSuppressFunc: suppressEquivalentManifest,
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Description: "Name of cluster, taken from the Cluster document",
+			Computed:    true,
+		},
+		"rolling_update_cloud_only": {
+			Type:        schema.TypeBool,
+			Description: "Replace instances without draining nodes or validating the cluster, the same as kops rolling-update cluster --cloudonly. Without it updates fail when the kubernetes api cannot be reached",
+			Optional:    true,
+			Default:     false,
+		},
+		"ssh_public_key": {
+			Type:        schema.TypeString,
+			Description: "ssh public key path",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"state_store": {
+			Type:        schema.TypeString,
+			Description: "State Store, e.g. s3://bucket or gs://bucket",
+			Required:    true,
+			ForceNew:    true,
+		},
+	}
+}
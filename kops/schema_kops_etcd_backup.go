@@ -0,0 +1,59 @@
+package kops
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+func kopsEtcdBackupSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"backup_name": {
+			Type:        schema.TypeString,
+			Description: "Backup to restore. In lookup mode the latest backup at creation time is recorded here",
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+		},
+		"backup_store": {
+			Type:        schema.TypeString,
+			Description: "VFS path of the etcd cluster's backups",
+			Computed:    true,
+		},
+		"backups": {
+			Type:        schema.TypeList,
+			Description: "Backups found in the backup store, oldest first",
+			Computed:    true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"cluster_name": {
+			Type:        schema.TypeString,
+			Description: "Name of cluster",
+			Required:    true,
+			ForceNew:    true,
+		},
+		"etcd_cluster": {
+			Type:         schema.TypeString,
+			Description:  "main or events",
+			Optional:     true,
+			ForceNew:     true,
+			Default:      "main",
+			ValidateFunc: validation.StringInSlice([]string{"main", "events"}, false),
+		},
+		"mode": {
+			Type:         schema.TypeString,
+			Description:  "lookup records the latest backup etcd-manager took on its own schedule, it does not take one. restore stages backup_name to be restored by etcd-manager",
+			Optional:     true,
+			ForceNew:     true,
+			Default:      "lookup",
+			ValidateFunc: validation.StringInSlice([]string{"lookup", "restore"}, false),
+		},
+		"state_store": {
+			Type:        schema.TypeString,
+			Description: "State Store",
+			Required:    true,
+			ForceNew:    true,
+		},
+	}
+}
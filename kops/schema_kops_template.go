@@ -0,0 +1,47 @@
+package kops
+
+import "github.com/hashicorp/terraform/helper/schema"
+
+func kopsTemplateSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"documents": {
+			Type:        schema.TypeList,
+			Description: "The rendered YAML documents, one per Cluster or InstanceGroup",
+			Computed:    true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"fail_on_missing": {
+			Type:        schema.TypeBool,
+			Description: "Fail when the template references a value that is not set",
+			Optional:    true,
+			Default:     true,
+		},
+		"rendered": {
+			Type:        schema.TypeString,
+			Description: "The rendered template, ready for kops_cluster_manifest",
+			Computed:    true,
+		},
+		"snippets": {
+			Type:        schema.TypeMap,
+			Description: "Snippets available to the template through the include function, keyed by name",
+			Optional:    true,
+		},
+		"template": {
+			Type:        schema.TypeString,
+			Description: "Go template of the Cluster and InstanceGroup documents",
+			Required:    true,
+		},
+		"values": {
+			Type:        schema.TypeMap,
+			Description: "Values passed to the template, set on top of values_yaml",
+			Optional:    true,
+		},
+		"values_yaml": {
+			Type:        schema.TypeString,
+			Description: "YAML values file content, for values that are not plain strings",
+			Optional:    true,
+		},
+	}
+}